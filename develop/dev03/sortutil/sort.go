@@ -0,0 +1,302 @@
+// Package sortutil - утилита sort из dev03 с вводом и выводом через io.Reader и io.Writer
+package sortutil
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Run выполняет sort с аргументами командной строки: сортирует строки файлов из args,
+// а без файлов - stdin, и возвращает код выхода
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return RunWith(func(name string) (io.ReadCloser, error) { return os.Open(name) }, args, stdin, stdout, stderr)
+}
+
+// RunWith - Run, который открывает файлы из args через open: так вызывающий решает,
+// от какого каталога считаются относительные имена
+func RunWith(open func(name string) (io.ReadCloser, error), args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	input := make([]string, 0)
+	files := 0
+	for _, v := range args {
+		if isFlag(v) {
+			continue
+		}
+		files++
+		file, err := open(v)
+		if err != nil {
+			fmt.Fprintln(stderr, "sort:", err)
+			return 2
+		}
+		input, err = readLines(file, input)
+		file.Close()
+		if err != nil {
+			fmt.Fprintln(stderr, "sort:", err)
+			return 2
+		}
+	}
+	if files == 0 {
+		var err error
+		if input, err = readLines(stdin, input); err != nil {
+			fmt.Fprintln(stderr, "sort:", err)
+			return 2
+		}
+	}
+
+	result, err := Sort(args, input)
+	if err != nil {
+		fmt.Fprintln(stderr, "sort:", err)
+		return 1
+	}
+	w := bufio.NewWriter(stdout)
+	for _, v := range result {
+		fmt.Fprintln(w, v)
+	}
+	if err = w.Flush(); err != nil {
+		return 2
+	}
+	return 0
+}
+
+// isFlag отличает флаг от имени файла: имена с точкой или слешем - всегда файлы
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && !strings.Contains(arg, ".") && !strings.Contains(arg, "/")
+}
+
+func readLines(r io.Reader, lines []string) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Sort сортирует строки по флагам утилиты: -n, -r, -u, -c, -k=N и -d=DELIM.
+// С -c строки не сортируются: для отсортированных возвращается nil, иначе ошибка
+func Sort(args []string, toSort []string) ([]string, error) {
+	flags := &strings.Builder{}
+	column := 0
+	delimiter := " "
+
+	if len(args) != 0 {
+		flags.Grow(len(args))
+	}
+	for _, v := range args {
+		if isFlag(v) {
+			if len(v) > 2 {
+				if v[1] == 'k' && v[2] == '=' {
+					c, err := strconv.Atoi(v[3:])
+					if err != nil {
+						return nil, err
+					}
+					column = c
+				} else if v[1] == 'd' && v[2] == '=' {
+					delimiter = v[3:]
+				} else {
+					flags.WriteString(v[1:])
+				}
+			} else {
+				flags.WriteString(v[1:])
+			}
+
+		}
+	}
+
+	isReverse := strings.Contains(flags.String(), "r")
+	isNumeric := strings.Contains(flags.String(), "n")
+	isByColumn := column > 0
+	if strings.Contains(flags.String(), "c") {
+		line, ok := check(toSort, isNumeric, isByColumn, column, delimiter)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("not sorted,  line %d", line))
+		} else {
+			return nil, nil
+		}
+	}
+	if strings.Contains(flags.String(), "u") {
+		toSort = unique(toSort)
+	}
+	if isNumeric {
+		numericSort(toSort, isReverse)
+	}
+	if isByColumn {
+		sortByColumn(toSort, column, delimiter, isNumeric, isReverse)
+	}
+	if !isNumeric && !isByColumn {
+		simpleSort(toSort, isReverse)
+	}
+
+	return toSort, nil
+}
+
+func check(in []string, isNumeric, isByColumn bool, column int, delimiter string) (uint64, bool) {
+	if len(in) < 2 {
+		return 0, true
+	}
+
+	for i := 1; i < len(in); i++ {
+		a, b := in[i-1], in[i]
+
+		if isNumeric && !isByColumn {
+			re := regexp.MustCompile(`[-]?\d[\d,]*[\.]?[\d{2}]*`)
+			x, y := re.FindAllString(a, -1), re.FindAllString(b, -1)
+
+			if len(x) == len(y) {
+				for n, sx := range x {
+					xf, _ := strconv.ParseFloat(sx, 64)
+					yf, _ := strconv.ParseFloat(y[n], 64)
+					if cmp.Compare(xf, yf) > 0 {
+						return uint64(i), false
+					}
+				}
+			}
+			if len(x) > len(y) {
+				return uint64(i), false
+			}
+		}
+
+		if isByColumn {
+			x, y := strings.Split(a, delimiter), strings.Split(b, delimiter)
+			if len(x) < column-1 || len(y) < column-1 {
+				return uint64(i), false
+			}
+			if !isNumeric && cmp.Compare(x[column-1], y[column-1]) > 0 {
+				return uint64(i), false
+			}
+			if isNumeric {
+				re := regexp.MustCompile(`[-]?\d[\d,]*[\.]?[\d{2}]*`)
+				x2, y2 := re.FindAllString(x[column-1], -1), re.FindAllString(y[column-1], -1)
+
+				if len(x2) == len(y2) {
+					for n, sx := range x2 {
+						xf, _ := strconv.ParseFloat(sx, 64)
+						yf, _ := strconv.ParseFloat(y2[n], 64)
+						if cmp.Compare(xf, yf) > 0 {
+							return uint64(i), false
+						}
+					}
+				}
+				if len(x2) > len(y2) {
+					return uint64(i), false
+				}
+			}
+		}
+
+		if !isNumeric && !isByColumn {
+			if cmp.Compare(a, b) > 0 {
+				return uint64(i), false
+			}
+		}
+	}
+
+	return 0, true
+}
+
+func simpleSort(in []string, isReverse bool) {
+	slices.SortFunc(in, func(a, b string) int {
+		if isReverse {
+			return -cmp.Compare(a, b)
+		}
+		return cmp.Compare(a, b)
+	})
+}
+
+func numericSort(in []string, isReverse bool) {
+	slices.SortFunc(in, func(a, b string) int {
+		return numericSortFunc(a, b, isReverse)
+	})
+}
+
+func unique(in []string) []string {
+	out := make([]string, 0, len(in))
+	m := make(map[string]struct{}, len(in))
+	for _, v := range in {
+		if _, ok := m[v]; !ok {
+			m[v] = struct{}{}
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func sortByColumn(in []string, column int, delimiter string, isNumeric, isReverse bool) {
+	slices.SortFunc(in, func(a, b string) int {
+		x, y := strings.Split(a, delimiter), strings.Split(b, delimiter)
+
+		if len(x) < column-1 && len(y) < column-1 {
+			return 0
+		}
+		if len(x) < column-1 && len(y) >= column-1 {
+			if isReverse {
+				return -1
+			}
+			return 1
+		}
+		if len(x) >= column-1 && len(y) < column-1 {
+			if isReverse {
+				return 1
+			}
+			return -1
+		}
+
+		if isNumeric {
+			return numericSortFunc(x[column-1], y[column-1], isReverse)
+		}
+
+		if isReverse {
+			return -cmp.Compare(x[column-1], y[column-1])
+		}
+		return cmp.Compare(x[column-1], y[column-1])
+	})
+}
+
+func numericSortFunc(a, b string, isReverse bool) int {
+	re := regexp.MustCompile(`[-]?\d[\d,]*[\.]?[\d{2}]*`)
+	x, y := re.FindAllString(a, -1), re.FindAllString(b, -1)
+	if len(x) == 0 && len(y) == 0 {
+		return 0
+	}
+
+	if isReverse {
+		if len(x) == len(y) {
+			for n, sx := range x {
+				xf, _ := strconv.ParseFloat(sx, 64)
+				yf, _ := strconv.ParseFloat(y[n], 64)
+				if cmp.Compare(xf, yf) != 0 {
+					return -cmp.Compare(xf, yf)
+				}
+			}
+			return 0
+		}
+		if len(x) < len(y) {
+			return 1
+		}
+		if len(x) > len(y) {
+			return -1
+		}
+	}
+	if len(x) == len(y) {
+		for n, sx := range x {
+			xf, _ := strconv.ParseFloat(sx, 64)
+			yf, _ := strconv.ParseFloat(y[n], 64)
+			if cmp.Compare(xf, yf) != 0 {
+				return cmp.Compare(xf, yf)
+			}
+		}
+		return 0
+	}
+	if len(x) < len(y) {
+		return -1
+	}
+	if len(x) > len(y) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package sortutil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{"Stdin", nil, "c\na\nb\n", "a\nb\nc\n", "", 0},
+		{"Numeric reversed", []string{"-nr"}, "lvl8\nlvl34\nlvl3\n", "lvl34\nlvl8\nlvl3\n", "", 0},
+		{"Unique", []string{"-u"}, "b\na\nb\n", "a\nb\n", "", 0},
+		{"Sorted check", []string{"-c"}, "a\nb\n", "", "", 0},
+		{"Unsorted check", []string{"-c"}, "b\na\n", "", "sort: not sorted,  line 1\n", 1},
+		{"Missing file", []string{"no_such.txt"}, "", "", "sort: open no_such.txt: no such file or directory\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if status := Run(tt.args, strings.NewReader(tt.stdin), &out, &errOut); status != tt.wantStatus {
+				t.Errorf("Run() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if errOut.String() != tt.wantErr {
+				t.Errorf("Run() stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_RunWith(t *testing.T) {
+	files := fstest.MapFS{
+		"a.txt":     {Data: []byte("34 54\n22 41\n")},
+		"dir/b.txt": {Data: []byte("1 88\n89 15\n")},
+	}
+	open := func(name string) (io.ReadCloser, error) { return files.Open(name) }
+	tests := []struct {
+		name       string
+		args       []string
+		wantOut    string
+		wantStatus int
+	}{
+		{"Files are merged", []string{"-n", "a.txt", "dir/b.txt"}, "1 88\n22 41\n34 54\n89 15\n", 0},
+		{"Sort by column", []string{"-n", "-k=2", "a.txt", "dir/b.txt"}, "89 15\n22 41\n34 54\n1 88\n", 0},
+		{"Missing file", []string{"a.txt", "c.txt"}, "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			// stdin не читается, если в args есть файлы
+			if status := RunWith(open, tt.args, strings.NewReader("stdin\n"), &out, io.Discard); status != tt.wantStatus {
+				t.Errorf("RunWith() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("RunWith() output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
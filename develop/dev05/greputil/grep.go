@@ -0,0 +1,338 @@
+// Package greputil - утилита grep из dev05 с вводом и выводом через io.Reader и io.Writer
+package greputil
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Options - флаги grep: строки контекста после (-A), до (-B) и вокруг (-C) совпадения,
+// подсчет (-c), без учета регистра (-i), инверсия (-v), строка вместо шаблона (-F)
+// и номера строк (-n)
+type Options struct {
+	After, Before, Context int
+	Count                  bool
+	IgnoreCase             bool
+	Invert                 bool
+	Fixed                  bool
+	LineNumber             bool
+}
+
+// Run выполняет grep с аргументами командной строки: ищет шаблон в файлах, а без файлов -
+// в stdin. Код выхода 0, если строки нашлись, 1 - если нет, 2 - при ошибке
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return RunWith(openFile, args, stdin, stdout, stderr)
+}
+
+// RunWith - Run, в котором файлы открывает open, например относительно каталога,
+// который не совпадает с текущим каталогом процесса
+func RunWith(open func(name string) (io.ReadCloser, error), args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := Options{}
+	fs := flag.NewFlagSet("grep", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.IntVar(&opts.After, "A", 0, "Print NUM lines of trailing context after matching lines.")
+	fs.IntVar(&opts.Before, "B", 0, "Print NUM lines of leading context before matching lines.")
+	fs.IntVar(&opts.Context, "C", 0, "Print NUM lines of output context.")
+	fs.BoolVar(&opts.Count, "c", false, "Suppress normal output; instead print a count of matching lines for each input file.")
+	fs.BoolVar(&opts.IgnoreCase, "i", false, "Ignore case distinctions in patterns and input data.")
+	fs.BoolVar(&opts.Invert, "v", false, "Invert the sense of matching, to select non-matching lines.")
+	fs.BoolVar(&opts.Fixed, "F", false, "Interpret PATTERN as fixed strings, not regular expressions.")
+	fs.BoolVar(&opts.LineNumber, "n", false, "Prefix each line of output with the line number within its input file.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if len(args) < 1 {
+		fmt.Fprintln(stderr, "grep: no pattern provided")
+		return 2
+	}
+
+	var out string
+	var matched int
+	var err error
+	if len(args) == 1 {
+		var lines []string
+		if lines, err = ReadLines(stdin); err == nil {
+			out, matched, err = Lines(opts, args[0], lines)
+		}
+	} else {
+		out, matched, err = grep(open, opts, args[0], args[1:])
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "grep:", err)
+		return 2
+	}
+	io.WriteString(stdout, out)
+	if matched == 0 {
+		return 1
+	}
+	return 0
+}
+
+// Grep ищет шаблон в файлах. Вывод по каждому файлу начинается строкой "имя:"
+// и заканчивается "---". matched - сколько строк совпало
+func Grep(opts Options, pattern string, files []string) (out string, matched int, err error) {
+	return grep(openFile, opts, pattern, files)
+}
+
+func grep(open func(string) (io.ReadCloser, error), opts Options, pattern string, files []string) (out string, matched int, err error) {
+	input, err := readFiles(open, files)
+	if err != nil {
+		return "", 0, err
+	}
+	return search(opts, pattern, files, input, true)
+}
+
+// Lines ищет шаблон в строках одного входа, например stdin, и выводит их без заголовков
+func Lines(opts Options, pattern string, lines []string) (out string, matched int, err error) {
+	return search(opts, pattern, []string{""}, map[string][]string{"": lines}, false)
+}
+
+func search(opts Options, pattern string, files []string, input map[string][]string, withNames bool) (string, int, error) {
+	if pattern == "" {
+		return "", 0, errors.New("no pattern provided")
+	}
+	if !opts.Fixed {
+		for _, p := range parsePatterns(pattern) {
+			if opts.IgnoreCase {
+				p = strings.ToLower(p)
+			}
+			if _, err := regexp.Compile(p); err != nil {
+				return "", 0, err
+			}
+		}
+	}
+	linesAfter := max(opts.After+opts.Context, 0)
+	linesBefore := max(opts.Before+opts.Context, 0)
+	if opts.Count {
+		out, matched := countMatches(pattern, files, input, opts.IgnoreCase, opts.Fixed, opts.Invert, withNames)
+		return out, matched, nil
+	}
+	out, matched := findMatches(pattern, files, input, uint(linesBefore), uint(linesAfter),
+		opts.IgnoreCase, opts.Fixed, opts.Invert, opts.LineNumber, withNames)
+	return out, matched, nil
+}
+
+func countMatches(
+	patterns string,
+	fileNames []string,
+	input map[string][]string,
+	isIgnoreCase bool,
+	isFixed bool,
+	isInvert bool,
+	withNames bool,
+) (string, int) {
+	out := &strings.Builder{}
+	total := 0
+	pttrns := parsePatterns(patterns)
+	for _, p := range pttrns {
+		var regex *regexp.Regexp
+		if !isFixed {
+			if isIgnoreCase {
+				p = strings.ToLower(p)
+			}
+			regex = regexp.MustCompile(p)
+		}
+		for _, fileName := range fileNames {
+			lines, ok := input[fileName]
+			if !ok {
+				continue
+			}
+			count := 0
+			for _, line := range lines {
+				if isIgnoreCase {
+					p = strings.ToLower(p)
+					line = strings.ToLower(line)
+				}
+
+				if isInvert {
+					if isFixed {
+						if !strings.Contains(line, p) {
+							count++
+						}
+					} else {
+						found := regex.FindAllString(line, -1)
+						if len(found) == 0 {
+							count++
+						}
+					}
+				} else {
+					if isFixed {
+						if strings.Contains(line, p) {
+							count++
+						}
+					} else {
+						found := regex.FindAllString(line, -1)
+						if len(found) > 0 {
+							count++
+						}
+					}
+				}
+			}
+			total += count
+			if !withNames {
+				out.WriteString(fmt.Sprint(count) + "\n")
+			} else if count > 0 {
+				out.WriteString(fileName + ":" + fmt.Sprint(count) + "\n")
+			}
+		}
+	}
+	return out.String(), total
+}
+
+func findMatches(
+	patterns string,
+	fileNames []string,
+	input map[string][]string,
+	linesBefore uint,
+	linesAfter uint,
+	isIgnoreCase bool,
+	isFixed bool,
+	isInvert bool,
+	isLineNum bool,
+	withNames bool,
+) (string, int) {
+	out := &strings.Builder{}
+	matched := 0
+	pttrns := parsePatterns(patterns)
+	for _, p := range pttrns {
+		var regex *regexp.Regexp
+		if !isFixed {
+			if isIgnoreCase {
+				p = strings.ToLower(p)
+			}
+			regex = regexp.MustCompile(p)
+		}
+		for _, fileName := range fileNames {
+			if withNames {
+				out.WriteString(fileName + ":\n")
+			}
+			lines, ok := input[fileName]
+			if !ok {
+				continue
+			}
+			for lineNum, line := range lines {
+				afterIdx := uint(min(lineNum+int(linesAfter), len(lines)-1))
+				beforeIdx := uint(max(lineNum-int(linesBefore), 0))
+				if isIgnoreCase {
+					p = strings.ToLower(p)
+					line = strings.ToLower(line)
+				}
+
+				if isInvert {
+					if isFixed {
+						if !strings.Contains(line, p) {
+							matched++
+							toPrint := lines[beforeIdx : afterIdx+1]
+							for n, l := range toPrint {
+								if isLineNum {
+									out.WriteString(fmt.Sprintf("%d:", beforeIdx+uint(n)))
+								}
+								out.WriteString(l + "\n")
+							}
+							if len(toPrint) > 1 {
+								out.WriteString("--")
+							}
+						}
+					} else {
+						found := regex.FindAllString(line, -1)
+						if len(found) == 0 {
+							matched++
+							toPrint := lines[beforeIdx : afterIdx+1]
+							for n, l := range toPrint {
+								if isLineNum {
+									out.WriteString(fmt.Sprintf("%d:", beforeIdx+uint(n)))
+								}
+								out.WriteString(l + "\n")
+							}
+							if len(toPrint) > 1 {
+								out.WriteString("--")
+							}
+						}
+					}
+				} else {
+					if isFixed {
+						if strings.Contains(line, p) {
+							matched++
+							toPrint := lines[beforeIdx : afterIdx+1]
+							for n, l := range toPrint {
+								if isLineNum {
+									out.WriteString(fmt.Sprintf("%d:", beforeIdx+uint(n)))
+								}
+								out.WriteString(l + "\n")
+							}
+							if len(toPrint) > 1 {
+								out.WriteString("--")
+							}
+						}
+					} else {
+						found := regex.FindAllString(line, -1)
+						if len(found) > 0 {
+							matched++
+							toPrint := lines[beforeIdx : afterIdx+1]
+							for n, l := range toPrint {
+								if isLineNum {
+									out.WriteString(fmt.Sprintf("%d:", beforeIdx+uint(n)))
+								}
+								out.WriteString(l + "\n")
+							}
+							if len(toPrint) > 1 {
+								out.WriteString("--\n")
+							}
+						}
+					}
+				}
+			}
+			if withNames {
+				out.WriteString("---\n")
+			}
+		}
+	}
+
+	return out.String(), matched
+}
+
+// ReadLines читает строки до конца ввода. Последняя строка может быть без перевода строки
+func ReadLines(r io.Reader) ([]string, error) {
+	input := make([]string, 0, 5)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		input = append(input, scanner.Text())
+	}
+	return input, scanner.Err()
+}
+
+func openFile(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func readFiles(open func(string) (io.ReadCloser, error), files []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+
+	for _, file := range files {
+		f, err := open(file)
+		if err != nil {
+			return nil, err
+		}
+		input, err := ReadLines(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		out[file] = input
+	}
+	return out, nil
+}
+
+func parsePatterns(s string) []string {
+	if strings.Contains(s, "|") {
+		return strings.Split(s, "|")
+	}
+	return []string{s}
+}
@@ -0,0 +1,74 @@
+package greputil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_Run(t *testing.T) {
+	const input = "Aenean massa.\nInteger tincidunt.\naenean leo.\n"
+	tests := []struct {
+		name       string
+		args       []string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{"Stdin", []string{"Aenean"}, "Aenean massa.\n", "", 0},
+		{"Ignore case", []string{"-i", "aenean"}, "Aenean massa.\naenean leo.\n", "", 0},
+		{"Invert", []string{"-v", "-i", "aenean"}, "Integer tincidunt.\n", "", 0},
+		{"Line numbers from 0", []string{"-n", "leo"}, "2:aenean leo.\n", "", 0},
+		{"Count", []string{"-c", "-i", "aenean"}, "2\n", "", 0},
+		{"Fixed string", []string{"-F", "massa."}, "Aenean massa.\n", "", 0},
+		{"No match", []string{"Lorem"}, "", "", 1},
+		{"No pattern", nil, "", "grep: no pattern provided\n", 2},
+		{"Bad regexp", []string{"("}, "", "grep: error parsing regexp: missing closing ): `(`\n", 2},
+		{"Missing file", []string{"a", "no_such.txt"}, "", "grep: open no_such.txt: no such file or directory\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if status := Run(tt.args, strings.NewReader(input), &out, &errOut); status != tt.wantStatus {
+				t.Errorf("Run() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if errOut.String() != tt.wantErr {
+				t.Errorf("Run() stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_RunWith(t *testing.T) {
+	files := fstest.MapFS{
+		"a.txt":     {Data: []byte("x 1\ny 2\n")},
+		"dir/b.txt": {Data: []byte("x 3\n")},
+	}
+	open := func(name string) (io.ReadCloser, error) { return files.Open(name) }
+	tests := []struct {
+		name       string
+		args       []string
+		wantOut    string
+		wantStatus int
+	}{
+		{"One file", []string{"x", "a.txt"}, "a.txt:\nx 1\n---\n", 0},
+		{"Several files", []string{"-c", "x", "a.txt", "dir/b.txt"}, "a.txt:1\ndir/b.txt:1\n", 0},
+		{"Missing file", []string{"x", "c.txt"}, "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if status := RunWith(open, tt.args, strings.NewReader("x stdin\n"), &out, io.Discard); status != tt.wantStatus {
+				t.Errorf("RunWith() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("RunWith() output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
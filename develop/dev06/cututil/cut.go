@@ -0,0 +1,140 @@
+// Package cututil - утилита cut из dev06 с вводом и выводом через io.Reader и io.Writer
+package cututil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Run выполняет cut с аргументами командной строки: читает строки файлов из args,
+// а без файлов - stdin, и возвращает код выхода
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return RunWith(func(name string) (io.ReadCloser, error) { return os.Open(name) }, args, stdin, stdout, stderr)
+}
+
+// RunWith работает как Run, но читает файлы, которые открывает open
+func RunWith(open func(name string) (io.ReadCloser, error), args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	input := make([]string, 0)
+	files := 0
+	for _, v := range args {
+		if isFlag(v) {
+			continue
+		}
+		files++
+		file, err := open(v)
+		if err != nil {
+			fmt.Fprintln(stderr, "cut:", err)
+			return 1
+		}
+		input, err = readLines(file, input)
+		file.Close()
+		if err != nil {
+			fmt.Fprintln(stderr, "cut:", err)
+			return 1
+		}
+	}
+	if files == 0 {
+		var err error
+		if input, err = readLines(stdin, input); err != nil {
+			fmt.Fprintln(stderr, "cut:", err)
+			return 1
+		}
+	}
+
+	output, err := Cut(input, args)
+	if err != nil {
+		fmt.Fprintln(stderr, "cut:", err)
+		return 1
+	}
+	io.WriteString(stdout, output)
+	return 0
+}
+
+// isFlag отличает флаг от имени файла: имена с точкой или слешем - всегда файлы
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && !strings.Contains(arg, ".") && !strings.Contains(arg, "/")
+}
+
+func readLines(r io.Reader, lines []string) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Cut выбирает из строк input поля по флагам утилиты: -f=LIST (поля с 0, например 0,2 или 1-3),
+// -d=DELIM и -s. Поля, которых в строке нет, пропускаются
+func Cut(input []string, args []string) (string, error) {
+	result := &strings.Builder{}
+	delimiter := "\t"
+	flags := &strings.Builder{}
+	fields := make([]uint, 0)
+
+	if len(args) != 0 {
+		flags.Grow(len(args))
+	}
+	for _, v := range args {
+		if isFlag(v) {
+			if len(v) > 2 {
+				if v[1] == 'f' && v[2] == '=' {
+					f := v[3:]
+					flds := strings.Split(f, ",")
+					for _, column := range flds {
+						if strings.Contains(column, "-") {
+							s := strings.Split(column, "-")
+							if len(s) == 2 {
+								c1, err1 := strconv.Atoi(s[0])
+								c2, err2 := strconv.Atoi(s[1])
+								if err1 == nil && err2 == nil {
+									for c := c1; c <= c2; c++ {
+										fields = append(fields, uint(c))
+									}
+								}
+							} else {
+								return "", errors.New("invalid field specification")
+							}
+						} else {
+							c, err := strconv.Atoi(column)
+							if err == nil && c >= 0 {
+								fields = append(fields, uint(c))
+							}
+						}
+					}
+				} else if v[1] == 'd' && v[2] == '=' {
+					delimiter = v[3:]
+				} else {
+					flags.WriteString(v[1:])
+				}
+			} else {
+				flags.WriteString(v[1:])
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return "", errors.New("you must specify a list of fields. For example -f=1,5 or -f=1-5")
+	}
+
+	isSeparated := strings.Contains(flags.String(), "s")
+	for _, v := range input {
+		if isSeparated {
+			if !strings.Contains(v, delimiter) {
+				continue
+			}
+		}
+		columns := strings.Split(v, delimiter)
+		for _, field := range fields {
+			if int(field) < len(columns) {
+				result.WriteString(columns[field] + delimiter)
+			}
+		}
+		result.WriteString("\n")
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,69 @@
+package cututil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_Run(t *testing.T) {
+	const input = "a\tb\tc\nno tabs\n1\t2\t3\n"
+	tests := []struct {
+		name       string
+		args       []string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{"Field from 0", []string{"-f=0"}, "a\t\nno tabs\t\n1\t\n", "", 0},
+		{"Range", []string{"-f=1-2"}, "b\tc\t\n\n2\t3\t\n", "", 0},
+		{"Only separated", []string{"-f=2", "-s"}, "c\t\n3\t\n", "", 0},
+		{"Delimiter", []string{"-d= ", "-f=1"}, "\ntabs \n\n", "", 0},
+		{"No fields", []string{"-s"}, "", "cut: you must specify a list of fields. For example -f=1,5 or -f=1-5\n", 1},
+		{"Missing file", []string{"-f=0", "no_such.txt"}, "", "cut: open no_such.txt: no such file or directory\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if status := Run(tt.args, strings.NewReader(input), &out, &errOut); status != tt.wantStatus {
+				t.Errorf("Run() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if errOut.String() != tt.wantErr {
+				t.Errorf("Run() stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_RunWith(t *testing.T) {
+	files := fstest.MapFS{
+		"a.txt":     {Data: []byte("a\tb\n")},
+		"dir/b.txt": {Data: []byte("c\td\n")},
+	}
+	open := func(name string) (io.ReadCloser, error) { return files.Open(name) }
+	tests := []struct {
+		name       string
+		args       []string
+		wantOut    string
+		wantStatus int
+	}{
+		{"Files are joined", []string{"-f=1", "a.txt", "dir/b.txt"}, "b\t\nd\t\n", 0},
+		{"Missing file", []string{"-f=1", "c.txt"}, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if status := RunWith(open, tt.args, strings.NewReader("x\ty\n"), &out, io.Discard); status != tt.wantStatus {
+				t.Errorf("RunWith() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("RunWith() output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
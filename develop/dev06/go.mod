@@ -0,0 +1,3 @@
+module dev06
+
+go 1.21
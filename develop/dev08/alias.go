@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// alias [name[=value] ...] задает псевдонимы, без значения печатает их
+func (sh *Shell) alias(args []string, std stdio) int {
+	if len(args) == 0 {
+		names := make([]string, 0, len(sh.aliases))
+		for name := range sh.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(std.out, "alias %s=%s\n", name, quote(sh.aliases[name]))
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue {
+			if value, ok := sh.aliases[name]; ok {
+				fmt.Fprintf(std.out, "alias %s=%s\n", name, quote(value))
+			} else {
+				fmt.Fprintf(std.err, "alias: %s: not found\n", name)
+				status = 1
+			}
+			continue
+		}
+		if name == "" || strings.ContainsAny(name, " \t\n/$`'\"\\|&;()<>=") {
+			fmt.Fprintf(std.err, "alias: `%s': invalid alias name\n", name)
+			status = 1
+			continue
+		}
+		sh.aliases[name] = value
+	}
+	return status
+}
+
+// unalias [-a] name ... удаляет псевдонимы, -a - все сразу
+func (sh *Shell) unalias(args []string, std stdio) int {
+	if len(args) == 1 && args[0] == "-a" {
+		sh.aliases = map[string]string{}
+		return 0
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(std.err, "unalias: usage: unalias [-a] name [name ...]")
+		return 2
+	}
+	status := 0
+	for _, name := range args {
+		if _, ok := sh.aliases[name]; !ok {
+			fmt.Fprintf(std.err, "unalias: %s: not found\n", name)
+			status = 1
+			continue
+		}
+		delete(sh.aliases, name)
+	}
+	return status
+}
+
+// expandAlias заменяет слово в позиции команды токенами его псевдонима. Псевдоним
+// раскрывается один раз, поэтому alias ls='ls -F' не зацикливается
+func (p *parser) expandAlias() {
+	expanded := map[string]bool{}
+	for {
+		name := p.keyword()
+		value, ok := p.aliases[name]
+		if !ok || expanded[name] {
+			return
+		}
+		tokens, err := lex(value)
+		if err != nil {
+			return
+		}
+		expanded[name] = true
+		tokens = tokens[:len(tokens)-1]
+		p.tokens = append(p.tokens[:p.pos], append(tokens, p.tokens[p.pos+1:]...)...)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// logicalDir - текущий каталог так, как до него дошли через cd, с символическими
+// ссылками. $PWD используется, только если он действительно указывает на текущий каталог
+func (sh *Shell) logicalDir() string {
+	if pwd, ok := sh.lookupVar("PWD"); ok && filepath.IsAbs(pwd) && pwd == filepath.Clean(pwd) {
+		logical, err1 := os.Stat(pwd)
+		current, err2 := os.Stat(sh.dir)
+		if err1 == nil && err2 == nil && os.SameFile(logical, current) {
+			return pwd
+		}
+	}
+	return sh.dir
+}
+
+// physicalDir - текущий каталог без символических ссылок
+func (sh *Shell) physicalDir() (string, error) {
+	return filepath.EvalSymlinks(sh.dir)
+}
+
+// checkDir проверяет, что в каталог dir можно перейти, как это проверил бы chdir(2)
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return &fs.PathError{Op: "chdir", Path: dir, Err: errors.Unwrap(err)}
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "chdir", Path: dir, Err: syscall.ENOTDIR}
+	}
+	if err = syscall.Access(dir, accessExecute); err != nil {
+		return &fs.PathError{Op: "chdir", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// pwdCommand - pwd [-L | -P]: логический путь по умолчанию, физический с -P
+func (sh *Shell) pwdCommand(args []string, std stdio) int {
+	physical := false
+	for _, arg := range args {
+		switch arg {
+		case "-L":
+			physical = false
+		case "-P":
+			physical = true
+		default:
+			fmt.Fprintf(std.err, "pwd: %s: invalid option\n", arg)
+			return 2
+		}
+	}
+	dir := sh.logicalDir()
+	if physical {
+		var err error
+		if dir, err = sh.physicalDir(); err != nil {
+			fmt.Fprintln(std.err, "pwd:", err)
+			return 1
+		}
+	}
+	fmt.Fprintln(std.out, dir)
+	return 0
+}
+
+// cdCommand - cd [-L | -P] [dir]: без аргумента переходит в $HOME
+func (sh *Shell) cdCommand(args []string, std stdio) int {
+	physical := false
+	for len(args) > 0 && (args[0] == "-L" || args[0] == "-P" || args[0] == "--") {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		physical = args[0] == "-P"
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(std.err, "Too many arguments")
+		return 1
+	}
+	path := ""
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if err := sh.cd(path, physical, std.out); err != nil {
+		fmt.Fprintln(std.err, err)
+		return 1
+	}
+	return 0
+}
+
+// cd меняет текущий каталог шелла и обновляет $PWD и $OLDPWD. Пустой path - это $HOME,
+// cd - возвращает в предыдущий каталог, относительный путь ищется и в каталогах $CDPATH.
+// Без physical путь строится от $PWD и .. убирает последний компонент пути,
+// а не поднимается из каталога, на который указывает символическая ссылка
+func (sh *Shell) cd(path string, physical bool, stdout io.Writer) error {
+	show := false
+	switch path {
+	case "":
+		home, ok := sh.lookupVar("HOME")
+		if !ok || home == "" {
+			return errors.New("cd: HOME not set")
+		}
+		path = home
+	case "-":
+		old, ok := sh.lookupVar("OLDPWD")
+		if !ok || old == "" {
+			return errors.New("cd: OLDPWD not set")
+		}
+		path, show = old, true
+	default:
+		if dir, ok := sh.searchCDPath(path); ok {
+			path, show = dir, true
+		}
+	}
+
+	prev := sh.logicalDir()
+	target := sh.path(path)
+	if !physical {
+		if !filepath.IsAbs(path) {
+			target = filepath.Join(prev, path)
+		}
+		target = filepath.Clean(target)
+	}
+	err := checkDir(target)
+	if err != nil && target != sh.path(path) {
+		// логический путь может не существовать, если .. выходит из-за ссылки
+		// в недоступный каталог, тогда путь разбирается как есть
+		if checkDir(sh.path(path)) == nil {
+			err, target, physical = nil, sh.path(path), true
+		}
+	}
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return fmt.Errorf("cd: %s: %v", path, pathErr.Err)
+		}
+		return err
+	}
+	if physical {
+		if target, err = filepath.EvalSymlinks(target); err != nil {
+			return err
+		}
+	}
+	sh.dir = target
+	sh.setVar("OLDPWD", prev)
+	sh.setVar("PWD", target)
+	if show {
+		fmt.Fprintln(stdout, target)
+	}
+	return nil
+}
+
+// searchCDPath ищет относительный каталог в $CDPATH. Пути, начинающиеся с . и ..,
+// в $CDPATH не ищутся. ok = true, только если каталог нашелся через непустой элемент $CDPATH
+func (sh *Shell) searchCDPath(path string) (string, bool) {
+	cdpath, _ := sh.lookupVar("CDPATH")
+	if cdpath == "" || filepath.IsAbs(path) || path == "." || path == ".." ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") {
+		return "", false
+	}
+	for _, dir := range strings.Split(cdpath, ":") {
+		if dir == "" {
+			dir = "."
+		}
+		candidate := filepath.Join(dir, path)
+		if info, err := os.Stat(sh.path(candidate)); err == nil && info.IsDir() {
+			if dir == "." {
+				return "", false
+			}
+			if !filepath.IsAbs(candidate) {
+				candidate = filepath.Join(sh.logicalDir(), candidate)
+			}
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// флаги R_OK и W_OK для access(2)
+const (
+	accessRead  = 0x4
+	accessWrite = 0x2
+)
+
+// testCommand - test expr: код 0, если выражение истинно, 1 - если ложно, 2 - при ошибке
+func (sh *Shell) testCommand(args []string, std stdio) int {
+	return runTest("test", sh.dir, args, std)
+}
+
+// bracketCommand - [ expr ], то же, что test, с обязательной ] в конце
+func (sh *Shell) bracketCommand(args []string, std stdio) int {
+	if len(args) == 0 || args[len(args)-1] != "]" {
+		fmt.Fprintln(std.err, "[: missing `]'")
+		return 2
+	}
+	return runTest("[", sh.dir, args[:len(args)-1], std)
+}
+
+func runTest(name, dir string, args []string, std stdio) int {
+	ok, err := evalTest(dir, args)
+	if err != nil {
+		fmt.Fprintf(std.err, "%s: %v\n", name, err)
+		return 2
+	}
+	if ok {
+		return 0
+	}
+	return 1
+}
+
+// evalTest вычисляет выражение. До четырех аргументов смысл определяется их числом,
+// как в POSIX: test -n - это проверка непустой строки "-n", а не оператор без операнда.
+// Относительные пути файлов считаются от каталога dir
+func evalTest(dir string, args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			return args[1] == "", nil
+		}
+		if isUnaryTest(args[0]) {
+			return unaryTest(dir, args[0], args[1])
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		if isBinaryTest(args[1]) {
+			return binaryTest(dir, args[0], args[1], args[2])
+		}
+		if args[0] == "!" {
+			ok, err := evalTest(dir, args[1:])
+			return !ok, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return args[1] != "", nil
+		}
+	case 4:
+		if args[0] == "!" {
+			ok, err := evalTest(dir, args[1:])
+			return !ok, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return evalTest(dir, args[1:3])
+		}
+	}
+	t := &testParser{dir: dir, args: args}
+	ok, err := t.or()
+	if err == nil && t.pos < len(args) {
+		err = fmt.Errorf("%s: unexpected argument", args[t.pos])
+	}
+	return ok, err
+}
+
+// testParser разбирает длинные выражения:
+//
+//	or      := and ('-o' and)*
+//	and     := not ('-a' not)*
+//	not     := '!' not | primary
+//	primary := '(' or ')' | unary-op arg | arg binary-op arg | arg
+type testParser struct {
+	dir  string
+	args []string
+	pos  int
+}
+
+func (t *testParser) or() (bool, error) {
+	ok, err := t.and()
+	for err == nil && t.pos < len(t.args) && t.args[t.pos] == "-o" {
+		t.pos++
+		var right bool
+		right, err = t.and()
+		ok = ok || right
+	}
+	return ok, err
+}
+
+func (t *testParser) and() (bool, error) {
+	ok, err := t.not()
+	for err == nil && t.pos < len(t.args) && t.args[t.pos] == "-a" {
+		t.pos++
+		var right bool
+		right, err = t.not()
+		ok = ok && right
+	}
+	return ok, err
+}
+
+func (t *testParser) not() (bool, error) {
+	if t.pos < len(t.args) && t.args[t.pos] == "!" {
+		t.pos++
+		ok, err := t.not()
+		return !ok, err
+	}
+	return t.primary()
+}
+
+func (t *testParser) primary() (bool, error) {
+	if t.pos >= len(t.args) {
+		return false, fmt.Errorf("argument expected")
+	}
+	arg := t.args[t.pos]
+	t.pos++
+	switch {
+	case arg == "(":
+		ok, err := t.or()
+		if err != nil {
+			return false, err
+		}
+		if t.pos >= len(t.args) || t.args[t.pos] != ")" {
+			return false, fmt.Errorf("`)' expected")
+		}
+		t.pos++
+		return ok, nil
+	case isUnaryTest(arg) && t.pos < len(t.args):
+		t.pos++
+		return unaryTest(t.dir, arg, t.args[t.pos-1])
+	case t.pos+1 < len(t.args) && isBinaryTest(t.args[t.pos]) && t.args[t.pos] != "-a" && t.args[t.pos] != "-o":
+		t.pos += 2
+		return binaryTest(t.dir, arg, t.args[t.pos-2], t.args[t.pos-1])
+	}
+	return arg != "", nil
+}
+
+func isUnaryTest(op string) bool {
+	return len(op) == 2 && op[0] == '-' && strings.IndexByte("bcdefhLnprsStwxz", op[1]) >= 0
+}
+
+func isBinaryTest(op string) bool {
+	switch op {
+	case "=", "==", "!=", "<", ">", "-eq", "-ne", "-lt", "-le", "-gt", "-ge", "-nt", "-ot", "-ef", "-a", "-o":
+		return true
+	}
+	return false
+}
+
+func unaryTest(dir, op, arg string) (bool, error) {
+	switch op {
+	case "-n":
+		return arg != "", nil
+	case "-z":
+		return arg == "", nil
+	case "-t":
+		fd, err := strconv.Atoi(arg)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", arg)
+		}
+		return isTerminal(fd), nil
+	case "-r":
+		return syscall.Access(resolvePath(dir, arg), accessRead) == nil, nil
+	case "-w":
+		return syscall.Access(resolvePath(dir, arg), accessWrite) == nil, nil
+	case "-x":
+		return syscall.Access(resolvePath(dir, arg), accessExecute) == nil, nil
+	case "-h", "-L":
+		info, err := os.Lstat(resolvePath(dir, arg))
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+	}
+
+	info, err := os.Stat(resolvePath(dir, arg))
+	if err != nil {
+		return false, nil
+	}
+	mode := info.Mode()
+	switch op {
+	case "-e":
+		return true, nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&os.ModeCharDevice != 0, nil
+	case "-p":
+		return mode&os.ModeNamedPipe != 0, nil
+	case "-S":
+		return mode&os.ModeSocket != 0, nil
+	}
+	return false, nil
+}
+
+func binaryTest(dir, left, op, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-a":
+		return left != "" && right != "", nil
+	case "-o":
+		return left != "" || right != "", nil
+	case "-nt", "-ot":
+		l, lErr := os.Stat(resolvePath(dir, left))
+		r, rErr := os.Stat(resolvePath(dir, right))
+		if op == "-ot" {
+			l, lErr, r, rErr = r, rErr, l, lErr
+		}
+		return lErr == nil && (rErr != nil || l.ModTime().After(r.ModTime())), nil
+	case "-ef":
+		l, lErr := os.Stat(resolvePath(dir, left))
+		r, rErr := os.Stat(resolvePath(dir, right))
+		return lErr == nil && rErr == nil && os.SameFile(l, r), nil
+	}
+
+	a, err := strconv.Atoi(strings.TrimSpace(left))
+	if err != nil {
+		return false, fmt.Errorf("%s: integer expression expected", left)
+	}
+	b, err := strconv.Atoi(strings.TrimSpace(right))
+	if err != nil {
+		return false, fmt.Errorf("%s: integer expression expected", right)
+	}
+	switch op {
+	case "-eq":
+		return a == b, nil
+	case "-ne":
+		return a != b, nil
+	case "-lt":
+		return a < b, nil
+	case "-le":
+		return a <= b, nil
+	case "-gt":
+		return a > b, nil
+	}
+	return a >= b, nil
+}
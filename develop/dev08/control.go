@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flowKind - прерывание выполнения списков командами break, continue и return
+type flowKind int
+
+const (
+	flowNone flowKind = iota
+	flowBreak
+	flowContinue
+	flowReturn
+	// flowInterrupt - команду на переднем плане прервали Ctrl+C, интерактивный шелл
+	// бросает и циклы, в которых она выполнялась
+	flowInterrupt
+)
+
+// flowState - прерывание и его цель: levels - сколько вложенных циклов прерывают
+// break n и continue n
+type flowState struct {
+	kind   flowKind
+	levels int
+}
+
+// savedVar - значение переменной до local, восстанавливается после выхода из функции
+type savedVar struct {
+	value    string
+	set      bool
+	exported bool
+}
+
+// runCompound выполняет составную команду в текущем шелле и возвращает ее код
+func (sh *Shell) runCompound(cmd command) int {
+	switch c := cmd.(type) {
+	case *subshellCommand:
+		sh.subshell(func(sub *Shell) { sub.runList(c.body) })
+		return sh.lastStatus
+	case *braceGroup:
+		sh.runList(c.body)
+		return sh.lastStatus
+	case *ifCommand:
+		return sh.runIf(c)
+	case *loopCommand:
+		return sh.runLoop(c)
+	case *forCommand:
+		return sh.runFor(c)
+	case *caseCommand:
+		return sh.runCase(c)
+	case *functionDef:
+		sh.functions[c.name] = c
+	}
+	return 0
+}
+
+// redirectsOf возвращает перенаправления составной команды
+func redirectsOf(cmd command) []*redirect {
+	switch c := cmd.(type) {
+	case *simpleCommand:
+		return c.redirects
+	case *subshellCommand:
+		return c.redirects
+	case *braceGroup:
+		return c.redirects
+	case *ifCommand:
+		return c.redirects
+	case *loopCommand:
+		return c.redirects
+	case *forCommand:
+		return c.redirects
+	case *caseCommand:
+		return c.redirects
+	}
+	return nil
+}
+
+// withStdio выполняет fn, подменив потоки шелла дескрипторами из fds:
+// команды внутри составной наследуют ее перенаправления
+func (sh *Shell) withStdio(fds *fdTable, fn func() int) int {
+	std := []**os.File{&sh.stdin, &sh.stdout, &sh.stderr}
+	saved := []*os.File{sh.stdin, sh.stdout, sh.stderr}
+	for fd, f := range std {
+		if file := fds.get(fd); file != nil {
+			*f = file
+		}
+	}
+	defer func() {
+		for fd, f := range std {
+			*f = saved[fd]
+		}
+	}()
+	return fn()
+}
+
+// runCondition выполняет условие if или цикла
+func (sh *Shell) runCondition(list *commandList) int {
+	sh.conditions++
+	defer func() { sh.conditions-- }()
+	sh.runList(list)
+	return sh.lastStatus
+}
+
+func (sh *Shell) runIf(c *ifCommand) int {
+	for i, cond := range c.conds {
+		status := sh.runCondition(cond)
+		if sh.flow.kind != flowNone {
+			return status
+		}
+		if status == 0 {
+			sh.runList(c.bodies[i])
+			return sh.lastStatus
+		}
+	}
+	if c.elseBody != nil {
+		sh.runList(c.elseBody)
+		return sh.lastStatus
+	}
+	return 0
+}
+
+// loopFlow обрабатывает break и continue после тела цикла и сообщает, продолжать ли его
+func (sh *Shell) loopFlow() bool {
+	switch sh.flow.kind {
+	case flowNone:
+		return true
+	case flowBreak, flowContinue:
+		sh.flow.levels--
+		if sh.flow.levels > 0 {
+			return false
+		}
+		kind := sh.flow.kind
+		sh.flow.kind = flowNone
+		return kind == flowContinue
+	}
+	return false
+}
+
+func (sh *Shell) runLoop(c *loopCommand) int {
+	sh.loops++
+	defer func() { sh.loops-- }()
+	status := 0
+	for {
+		ok := sh.runCondition(c.cond) == 0
+		if sh.flow.kind != flowNone {
+			if sh.loopFlow() {
+				continue
+			}
+			break
+		}
+		if ok == c.until {
+			break
+		}
+		sh.runList(c.body)
+		status = sh.lastStatus
+		if !sh.loopFlow() {
+			break
+		}
+	}
+	return status
+}
+
+func (sh *Shell) runFor(c *forCommand) int {
+	values := append([]string{}, sh.positional...)
+	if c.in {
+		values = values[:0]
+		for _, w := range c.words {
+			fields, err := sh.expandWord(w)
+			if err != nil {
+				sh.reportExpansionError(err)
+				return 1
+			}
+			values = append(values, fields...)
+		}
+	}
+	sh.loops++
+	defer func() { sh.loops-- }()
+	status := 0
+	for _, value := range values {
+		sh.setVar(c.name, value)
+		sh.runList(c.body)
+		status = sh.lastStatus
+		if !sh.loopFlow() {
+			break
+		}
+	}
+	return status
+}
+
+func (sh *Shell) runCase(c *caseCommand) int {
+	subject, err := sh.expandString(c.subject)
+	if err != nil {
+		sh.reportExpansionError(err)
+		return 1
+	}
+	for _, item := range c.items {
+		for _, w := range item.patterns {
+			pattern, err := sh.expandPattern(w)
+			if err != nil {
+				sh.reportExpansionError(err)
+				return 1
+			}
+			if matchPattern(pattern, subject) {
+				sh.lastStatus = 0
+				sh.runList(item.body)
+				return sh.lastStatus
+			}
+		}
+	}
+	return 0
+}
+
+// callFunction выполняет функцию с аргументами args. Присваивания перед ее именем
+// действуют, пока она выполняется, и видны запущенным из нее командам
+func (sh *Shell) callFunction(f *functionDef, args []string, assigns []assignment) int {
+	frame := map[string]savedVar{}
+	for _, a := range assigns {
+		sh.saveVar(frame, a.name)
+		sh.unsetVar(a.name)
+		sh.env[a.name] = a.value
+	}
+	sh.frames = append(sh.frames, frame)
+	savedArgs, savedLoops := sh.positional, sh.loops
+	sh.positional, sh.loops = args, 0
+	sh.calls++
+	defer func() {
+		sh.calls--
+		sh.positional, sh.loops = savedArgs, savedLoops
+		sh.frames = sh.frames[:len(sh.frames)-1]
+		sh.restoreVars(frame)
+	}()
+
+	fds := newFdTable(sh.stdin, sh.stdout, sh.stderr)
+	defer fds.close()
+	if err := sh.redirect(fds, redirectsOf(f.body)); err != nil {
+		fmt.Fprintln(sh.stderr, err)
+		return 1
+	}
+	status := sh.withStdio(fds, func() int { return sh.runCompound(f.body) })
+	if sh.flow.kind == flowReturn {
+		sh.flow.kind = flowNone
+		status = sh.lastStatus
+	}
+	return status
+}
+
+// saveVar запоминает значение переменной в кадре, если оно еще не запомнено
+func (sh *Shell) saveVar(frame map[string]savedVar, name string) bool {
+	if _, ok := frame[name]; ok {
+		return false
+	}
+	v := savedVar{}
+	v.value, v.set = sh.vars[name]
+	if value, ok := sh.env[name]; ok {
+		v = savedVar{value, true, true}
+	}
+	frame[name] = v
+	return true
+}
+
+func (sh *Shell) restoreVars(frame map[string]savedVar) {
+	for name, v := range frame {
+		sh.unsetVar(name)
+		switch {
+		case v.exported:
+			sh.env[name] = v.value
+		case v.set:
+			sh.vars[name] = v.value
+		}
+	}
+}
+
+// parentVar передает дочернему шеллу состояние родителя, как если бы он был его fork:
+// $?, $$, $! и команды, восстанавливающие переменные, функции и флаги. Значение -
+// "код:pid шелла:pid фонового процесса;команды"
+const parentVar = "DEV08_PARENT"
+
+// childShell возвращает аргументы и окружение дочернего шелла, который выполнит
+// стадию конвейера cmd с раскрытыми словами argv
+func (sh *Shell) childShell(cmd command, argv, env []string) ([]string, []string) {
+	script := cmd.String()
+	if _, ok := cmd.(*simpleCommand); ok {
+		fields := make([]string, 0, len(argv))
+		for _, arg := range argv {
+			fields = append(fields, quote(arg))
+		}
+		script = strings.Join(fields, " ")
+	}
+	args := append([]string{"-c", script, sh.name}, sh.positional...)
+	parent := fmt.Sprintf("%s=%d:%d:%d;%s", parentVar, sh.lastStatus, sh.pid, sh.lastBackgroundPid, sh.shellState())
+	return args, append(env[:len(env):len(env)], parent)
+}
+
+// restoreParent восстанавливает состояние, переданное родительским шеллом. $?
+// задается последним: присваивания и определения функций его сбрасывают
+func (sh *Shell) restoreParent() {
+	value, ok := sh.env[parentVar]
+	if !ok {
+		return
+	}
+	delete(sh.env, parentVar)
+	spec, state, _ := strings.Cut(value, ";")
+	sh.runSource(strings.NewReader(state))
+	fmt.Sscanf(spec, "%d:%d:%d", &sh.lastStatus, &sh.pid, &sh.lastBackgroundPid)
+}
+
+// shellState - команды, которые восстанавливают в дочернем шелле функции,
+// неэкспортированные переменные и флаги
+func (sh *Shell) shellState() string {
+	b := &strings.Builder{}
+	names := make([]string, 0, len(sh.vars))
+	for name := range sh.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s=%s\n", name, quote(sh.vars[name]))
+	}
+	names = names[:0]
+	for name := range sh.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(b, sh.functions[name])
+	}
+	if sh.options.errexit {
+		fmt.Fprintln(b, "set -e")
+	}
+	if sh.options.xtrace {
+		fmt.Fprintln(b, "set -x")
+	}
+	// дочерний шелл начинает с включенным coreutils
+	if !sh.options.coreutils {
+		fmt.Fprintln(b, "set +o coreutils")
+	}
+	return b.String()
+}
+
+// local NAME[=value] ... - переменные, которые восстановятся после выхода из функции
+func (sh *Shell) local(args []string, std stdio) int {
+	if len(sh.frames) == 0 {
+		fmt.Fprintln(std.err, "local: can only be used in a function")
+		return 1
+	}
+	frame := sh.frames[len(sh.frames)-1]
+	status := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			fmt.Fprintf(std.err, "local: `%s': not a valid identifier\n", arg)
+			status = 1
+			continue
+		}
+		if sh.saveVar(frame, name) {
+			sh.unsetVar(name)
+		}
+		if hasValue {
+			sh.setVar(name, value)
+		}
+	}
+	return status
+}
+
+// returnCommand - return [n]: выход из функции или из файла, выполняемого source
+func (sh *Shell) returnCommand(args []string, std stdio) int {
+	if sh.calls == 0 {
+		fmt.Fprintln(std.err, "return: can only `return' from a function or sourced script")
+		return 1
+	}
+	status := sh.lastStatus
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(std.err, "return: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	sh.flow.kind = flowReturn
+	return status
+}
+
+// loopControl - break [n] и continue [n]
+func (sh *Shell) loopControl(name string, kind flowKind, args []string, std stdio) int {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			fmt.Fprintf(std.err, "%s: %s: loop count out of range\n", name, args[0])
+			return 1
+		}
+	}
+	if sh.loops == 0 {
+		fmt.Fprintf(std.err, "%s: only meaningful in a `for', `while', or `until' loop\n", name)
+		return 0
+	}
+	sh.flow.kind, sh.flow.levels = kind, min(n, sh.loops)
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"dev03/sortutil"
+	"dev05/greputil"
+	"dev06/cututil"
+	"dev09/wgetutil"
+)
+
+// coreutils - утилиты из соседних заданий и cat, которые выполняются в самом шелле
+// вместо одноименных программ из $PATH, так что конвейер вроде
+// cat f | grep -i x | sort -n | cut -f=2 работает без внешних программ.
+// Режим включен по умолчанию, set +o coreutils возвращает программы из $PATH
+var coreutils = map[string]builtin{
+	"cat": (*Shell).cat,
+	"sort": func(sh *Shell, args []string, std stdio) int {
+		return sortutil.RunWith(sh.openFile, args, std.in, std.out, std.err)
+	},
+	"grep": func(sh *Shell, args []string, std stdio) int {
+		return greputil.RunWith(sh.openFile, args, std.in, std.out, std.err)
+	},
+	"cut": func(sh *Shell, args []string, std stdio) int {
+		return cututil.RunWith(sh.openFile, args, std.in, std.out, std.err)
+	},
+	"wget": func(sh *Shell, args []string, std stdio) int {
+		return wgetutil.RunIn(sh.dir, args, std.out, std.err)
+	},
+}
+
+// lookupBuiltin ищет встроенную команду, не меняющую состояние шелла
+func (sh *Shell) lookupBuiltin(name string) (builtin, bool) {
+	if b, ok := builtins[name]; ok {
+		return b, true
+	}
+	if sh.options.coreutils {
+		b, ok := coreutils[name]
+		return b, ok
+	}
+	return nil, false
+}
+
+// cat [file...] - выводит файлы по очереди, без файлов или для "-" - stdin
+func (sh *Shell) cat(args []string, std stdio) int {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	status := 0
+	for _, name := range args {
+		if name == "-" {
+			if _, err := io.Copy(std.out, std.in); err != nil {
+				fmt.Fprintln(std.err, "cat:", err)
+				status = 1
+			}
+			continue
+		}
+		f, err := sh.openFile(name)
+		if err == nil {
+			_, err = io.Copy(std.out, f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintln(std.err, "cat:", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// openFile открывает файл, который утилита читает, относительно каталога шелла
+func (sh *Shell) openFile(name string) (io.ReadCloser, error) {
+	f, err := sh.open(name, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// stdio - потоки встроенной команды. Builtin читает и пишет только через них,
+// поэтому его вывод можно перенаправить в файл или канал
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// builtin - встроенная команда, которая может быть стадией конвейера
+type builtin func(sh *Shell, args []string, std stdio) int
+
+var builtins = map[string]builtin{
+	"echo": func(sh *Shell, args []string, std stdio) int {
+		echo(args, std.out)
+		return 0
+	},
+	"pwd": (*Shell).pwdCommand,
+	"ps": func(sh *Shell, args []string, std stdio) int {
+		return ps(args, std.out, std.err)
+	},
+	"kill":    (*Shell).kill,
+	"pkill":   (*Shell).pkill,
+	"which":   (*Shell).which,
+	"jobs":    (*Shell).jobsCommand,
+	"history": (*Shell).historyCommand,
+}
+
+func init() {
+	// type и hash обращаются к таблице builtins, поэтому добавляются после ее инициализации
+	builtins["type"] = (*Shell).typeCommand
+	builtins["hash"] = (*Shell).hashCommand
+	builtins["test"] = (*Shell).testCommand
+	builtins["["] = (*Shell).bracketCommand
+	// source выполняет команды, которые снова ищутся в shellBuiltins
+	shellBuiltins["source"] = (*Shell).source
+	shellBuiltins["."] = (*Shell).source
+}
+
+// shellBuiltins меняют состояние самого шелла. Как и в sh, внутри конвейера
+// из нескольких команд они ни на что не влияют
+var shellBuiltins = map[string]builtin{
+	"cd":      (*Shell).cdCommand,
+	"ulimit":  (*Shell).ulimit,
+	"alias":   (*Shell).alias,
+	"unalias": (*Shell).unalias,
+	"fg":      (*Shell).fgCommand,
+	"bg":      (*Shell).bgCommand,
+	"wait":    (*Shell).waitCommand,
+	"export":  (*Shell).export,
+	"unset":   (*Shell).unset,
+	"read":    (*Shell).read,
+	"set":     (*Shell).setCommand,
+	"shift":   (*Shell).shift,
+	"exit":    (*Shell).exitCommand,
+	"local":   (*Shell).local,
+	"return":  (*Shell).returnCommand,
+	"break": func(sh *Shell, args []string, std stdio) int {
+		return sh.loopControl("break", flowBreak, args, std)
+	},
+	"continue": func(sh *Shell, args []string, std stdio) int {
+		return sh.loopControl("continue", flowContinue, args, std)
+	},
+	`\quit`: func(sh *Shell, args []string, std stdio) int {
+		panic(exitShell(0))
+	},
+}
+
+func (sh *Shell) runList(list *commandList) {
+	for _, item := range list.items {
+		if sh.flow.kind != flowNone {
+			return
+		}
+		if item.background {
+			sh.runBackground(item)
+			continue
+		}
+		ranLast := false
+		sh.runAndOr(item, func(pl *pipeline) int {
+			if sh.flow.kind != flowNone {
+				return sh.lastStatus
+			}
+			ranLast = pl == item.pipelines[len(item.pipelines)-1] && !pl.negate
+			if !ranLast {
+				// set -e не срабатывает на конвейерах перед && и || и на конвейерах
+				// с !: их код - условие
+				sh.conditions++
+				defer func() { sh.conditions-- }()
+			}
+			sh.lastStatus = sh.runPipeline(pl)
+			return sh.lastStatus
+		})
+		if sh.options.errexit && sh.conditions == 0 && sh.flow.kind == flowNone && sh.lastStatus != 0 && ranLast {
+			panic(exitShell(sh.lastStatus))
+		}
+	}
+}
+
+// runAndOr выполняет конвейеры слева направо: после && следующий запускается
+// только при успехе предыдущего, после || - только при ошибке
+func (sh *Shell) runAndOr(item *andOrList, run func(pl *pipeline) int) int {
+	status := run(item.pipelines[0])
+	for i, op := range item.ops {
+		if (op == tokAnd) != (status == 0) {
+			continue
+		}
+		status = run(item.pipelines[i+1])
+	}
+	return status
+}
+
+// runBackground запускает список в фоне. Одиночный конвейер становится заданием
+// со своей группой процессов; список из нескольких конвейеров или конвейер с ! или time
+// выполняется в горутине как одно задание, его конвейеры запускаются фоновыми заданиями без номеров
+func (sh *Shell) runBackground(item *andOrList) {
+	var j *job
+	if len(item.pipelines) == 1 && !item.pipelines[0].negate && !item.pipelines[0].timed {
+		j = sh.startJob(item.pipelines[0], false, sh.timers)
+		if last := j.procs[len(j.procs)-1]; last.pid != 0 {
+			sh.lastBackgroundPid = last.pid
+		}
+	} else {
+		j = &job{cmd: item.String()}
+		p := &process{}
+		sh.jobs.add(j)
+		sh.jobs.addProcess(j, p)
+		go func() {
+			defer func() {
+				// exit в фоновом списке завершает только его
+				if r := recover(); r != nil {
+					code, ok := r.(exitShell)
+					if !ok {
+						panic(r)
+					}
+					sh.jobs.finish(p, int(code))
+				}
+			}()
+			sh.jobs.finish(p, sh.runAndOr(item, func(pl *pipeline) int {
+				// sh.timers принадлежит шеллу, фоновый список замеряет свои конвейеры сам
+				var timed []*cpuTimer
+				if pl.timed {
+					timer := &cpuTimer{start: time.Now()}
+					defer sh.report(timer, pl.posixTime)
+					timed = []*cpuTimer{timer}
+				}
+				inner := sh.startJob(pl, false, timed)
+				_, status := sh.jobs.wait(inner)
+				sh.jobs.remove(inner)
+				return pl.status(status)
+			}))
+		}()
+	}
+	sh.jobs.setCurrent(j)
+	if sh.terminal.enabled && j.pgid != 0 {
+		fmt.Fprintf(sh.stderr, "[%d] %d\n", j.id, j.pgid)
+	} else if sh.terminal.enabled {
+		fmt.Fprintf(sh.stderr, "[%d]\n", j.id)
+	}
+	sh.lastStatus = 0
+}
+
+// runPipeline выполняет конвейер на переднем плане и возвращает код возврата последней стадии
+func (sh *Shell) runPipeline(pl *pipeline) int {
+	if pl.timed {
+		defer sh.measure(pl.posixTime)()
+	}
+	status := sh.foreground(sh.startJob(pl, true, sh.timers))
+	if sh.terminal.interactive && status == 128+int(syscall.SIGINT) {
+		sh.flow.kind = flowInterrupt
+	}
+	return pl.status(status)
+}
+
+// status возвращает код конвейера по коду его последней стадии: с ! он инвертируется
+func (pl *pipeline) status(last int) int {
+	if !pl.negate {
+		return last
+	}
+	if last == 0 {
+		return 1
+	}
+	return 0
+}
+
+// startJob запускает все стадии конвейера одновременно, соединяя stdout каждой
+// со stdin следующей через os.Pipe. При управлении заданиями процессы конвейера
+// попадают в одну группу, которой на переднем плане передается терминал.
+// Команда из shellBuiltins и присваивания без команды выполняются в самом шелле,
+// только если они - весь конвейер. Время процессов задания достается замерам timers
+func (sh *Shell) startJob(pl *pipeline, fg bool, timers []*cpuTimer) *job {
+	j := &job{cmd: pl.String(), timers: slices.Clone(timers)}
+	sh.jobs.add(j)
+	// запуски до регистрации процессов могли пропустить SIGCHLD
+	defer sh.jobs.reap()
+
+	stdin := sh.stdin
+	alone := len(pl.commands) == 1
+	for i, cmd := range pl.commands {
+		fds := newFdTable(stdin, sh.stdout, sh.stderr)
+		if stdin != sh.stdin {
+			fds.owned = append(fds.owned, stdin)
+		} else if !fg && !sh.terminal.enabled {
+			// без управления заданиями фоновая команда не должна читать терминал
+			if devNull, err := os.Open(os.DevNull); err == nil {
+				fds.set(0, devNull)
+				fds.owned = append(fds.owned, devNull)
+			}
+		}
+		var nextStdin *os.File
+		if i < len(pl.commands)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				fmt.Fprintln(sh.stderr, err)
+				fds.close()
+				sh.jobs.addProcess(j, &process{state: jobDone, status: 1})
+				return j
+			}
+			fds.set(1, w)
+			fds.owned = append(fds.owned, w)
+			nextStdin = r
+		}
+
+		var assigns []assignment
+		var argv []string
+		var err error
+		simple, isSimple := cmd.(*simpleCommand)
+		if isSimple {
+			sh.substitutionStatus = 0
+			assigns, argv, err = sh.expand(simple)
+			if err == nil && sh.options.xtrace {
+				sh.trace(assigns, argv)
+			}
+		}
+		if err == nil {
+			err = sh.redirect(fds, redirectsOf(cmd))
+		}
+		var function *functionDef
+		if len(argv) > 0 {
+			function = sh.functions[argv[0]]
+		}
+
+		if err != nil {
+			fds.close()
+			sh.jobs.addProcess(j, &process{state: jobDone, status: 1})
+			// стадия конвейера и фоновая команда в sh - подоболочки, ошибка
+			// ${name:?} завершает только их
+			if alone && fg {
+				sh.reportExpansionError(err)
+			} else {
+				fmt.Fprintln(sh.stderr, err)
+			}
+		} else if (!isSimple || function != nil) && alone && fg {
+			status := sh.runInShell(j, fds, func() int {
+				if function != nil {
+					return sh.callFunction(function, argv[1:], assigns)
+				}
+				return sh.withStdio(fds, func() int { return sh.runCompound(cmd) })
+			})
+			fds.close()
+			sh.jobs.addProcess(j, &process{state: jobDone, status: status})
+		} else if !isSimple || function != nil {
+			// составная команда в конвейере или в фоне выполняется дочерним шеллом,
+			// как в sh, где для нее делается fork
+			args, env := sh.childShell(cmd, argv, sh.childEnv(assigns))
+			sh.spawn(j, fg, shellPath(), args, fds, env, false)
+		} else if len(argv) == 0 {
+			if alone {
+				for _, a := range assigns {
+					sh.setVar(a.name, a.value)
+				}
+			}
+			fds.close()
+			sh.jobs.addProcess(j, &process{state: jobDone, status: sh.substitutionStatus})
+		} else if b, ok := shellBuiltins[argv[0]]; ok {
+			status := 0
+			if alone {
+				status = sh.runInShell(j, fds, func() int { return b(sh, argv[1:], fds.stdio()) })
+			}
+			fds.close()
+			sh.jobs.addProcess(j, &process{state: jobDone, status: status})
+		} else if argv[0] == "timeout" {
+			sh.timeout(j, fg, argv[1:], fds, sh.childEnv(assigns))
+		} else if b, ok := sh.lookupBuiltin(argv[0]); ok {
+			p := &process{}
+			sh.jobs.addProcess(j, p)
+			// builtin в горутине получает копию шелла: шелл тем временем
+			// выполняет следующие команды и меняет свои переменные
+			go func(b builtin, sub *Shell, args []string, fds *fdTable, p *process) {
+				status := b(sub, args, fds.stdio())
+				fds.close()
+				sh.jobs.finish(p, status)
+			}(b, sh.clone(), argv[1:], fds, p)
+		} else {
+			sh.spawn(j, fg, argv[0], argv[1:], fds, sh.childEnv(assigns), false)
+		}
+		stdin = nextStdin
+	}
+	return j
+}
+
+// runInShell выполняет fn в самом шелле. exit внутри fn раскручивает стек
+// до подоболочки или main, поэтому файлы и задание j убираются сразу
+func (sh *Shell) runInShell(j *job, fds *fdTable, fn func() int) int {
+	defer func() {
+		if r := recover(); r != nil {
+			fds.close()
+			sh.jobs.remove(j)
+			panic(r)
+		}
+	}()
+	return fn()
+}
+
+// spawn запускает процесс стадии конвейера в группе процессов задания j и возвращает
+// его или nil, если запустить не удалось. Без управления заданиями процесс остается
+// в группе шелла, а с ownGroup получает свою
+func (sh *Shell) spawn(j *job, fg bool, name string, args []string, fds *fdTable, env []string, ownGroup bool) *process {
+	sys := &syscall.SysProcAttr{}
+	if sh.terminal.enabled {
+		sys.Setpgid, sys.Pgid = true, j.pgid
+		if j.pgid == 0 && fg {
+			sys.Foreground, sys.Ctty = true, sh.terminal.fd
+		}
+	} else if ownGroup {
+		sys.Setpgid = true
+	}
+	// после запуска процесса родителю его концы каналов и файлы не нужны
+	defer fds.close()
+	p, err := sh.forkexec(name, args, fds.files, env, sys)
+	if err != nil {
+		fmt.Fprintln(fds.stdio().err, err)
+		sh.jobs.addProcess(j, &process{state: jobDone, status: exitStatusOf(err)})
+		return nil
+	}
+	if sh.terminal.enabled && j.pgid == 0 {
+		j.pgid = p.Pid
+	}
+	proc := &process{pid: p.Pid}
+	sh.jobs.addProcess(j, proc)
+	// процесс ждет reap через wait4, os.Process больше не нужен
+	p.Release()
+	return proc
+}
+
+// shellPath - исполняемый файл шелла для дочерних шеллов
+func shellPath() string {
+	if path, err := os.Executable(); err == nil {
+		return path
+	}
+	return os.Args[0]
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strings"
+	"unicode/utf8"
+)
+
+// assignment - присваивание NAME=value перед именем команды
+type assignment struct {
+	name  string
+	value string
+}
+
+// declarationBuiltins получают аргументы-присваивания без деления на поля, как в bash:
+// export A=$B не распадается на несколько аргументов, даже если в $B есть пробелы
+var declarationBuiltins = map[string]bool{"export": true, "local": true}
+
+// unsetParameterError - ошибка ${name:?message}. В отличие от прочих ошибок раскрытия
+// она завершает неинтерактивный шелл
+type unsetParameterError struct {
+	name    string
+	message string
+}
+
+func (e unsetParameterError) Error() string {
+	return e.name + ": " + e.message
+}
+
+// reportExpansionError печатает ошибку раскрытия слов или перенаправлений. После
+// ${name:?} скрипт и sh -c завершаются с кодом 1, интерактивный шелл продолжает работу
+func (sh *Shell) reportExpansionError(err error) {
+	fmt.Fprintln(sh.stderr, err)
+	if errors.As(err, new(unsetParameterError)) && !sh.terminal.interactive {
+		panic(exitShell(1))
+	}
+}
+
+// expand раскрывает слова команды: присваивания в начале, затем имя и аргументы
+func (sh *Shell) expand(c *simpleCommand) ([]assignment, []string, error) {
+	assigns := make([]assignment, 0)
+	i := 0
+	for ; i < len(c.words); i++ {
+		name, value, ok := splitAssignment(c.words[i])
+		if !ok {
+			break
+		}
+		expanded, err := sh.expandString(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		assigns = append(assigns, assignment{name, expanded})
+	}
+
+	args := make([]string, 0, len(c.words)-i)
+	for j, w := range c.words[i:] {
+		if j > 0 && declarationBuiltins[args[0]] {
+			if _, _, ok := splitAssignment(w); ok {
+				value, err := sh.expandString(w)
+				if err != nil {
+					return nil, nil, err
+				}
+				args = append(args, value)
+				continue
+			}
+		}
+		fields, err := sh.expandWord(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, fields...)
+	}
+	// лексер снимает обратный слеш как экранирование, и \quit превращается в quit.
+	// Команда выхода узнается по экранированной первой букве
+	if len(args) > 0 && i < len(c.words) {
+		if w := c.words[i]; len(w) > 1 && w[0].quote == singleQuoted && len(w[0].text) == 1 {
+			if _, ok := shellBuiltins[`\`+args[0]]; ok {
+				args[0] = `\` + args[0]
+			}
+		}
+	}
+	return assigns, args, nil
+}
+
+// splitAssignment разбирает слово вида NAME=value. Имя и = должны быть без кавычек
+func splitAssignment(w word) (string, word, bool) {
+	if len(w) == 0 || w[0].quote != unquoted {
+		return "", nil, false
+	}
+	name, rest, ok := strings.Cut(w[0].text, "=")
+	if !ok || !isName(name) {
+		return "", nil, false
+	}
+	value := make(word, 0, len(w))
+	if rest != "" {
+		value = append(value, wordPart{rest, unquoted})
+	}
+	return name, append(value, w[1:]...), true
+}
+
+// expandWord раскрывает слово в поля: результат подстановок вне кавычек делится по $IFS,
+// слово без кавычек, раскрывшееся в пустую строку, исчезает, поля с шаблонами
+// раскрываются в подходящие пути
+func (sh *Shell) expandWord(w word) ([]string, error) {
+	e := &expander{sh: sh, split: true}
+	if err := e.word(w); err != nil {
+		return nil, err
+	}
+	e.endField()
+	return e.fields, nil
+}
+
+// expandString раскрывает слово в одну строку без деления на поля и шаблонов:
+// для присваиваний, перенаправлений и heredoc
+func (sh *Shell) expandString(w word) (string, error) {
+	e := &expander{sh: sh}
+	if err := e.word(w); err != nil {
+		return "", err
+	}
+	return e.field.String(), nil
+}
+
+// expandPattern раскрывает слово в шаблон для case: символы шаблонов из кавычек
+// экранируются и сравниваются буквально
+func (sh *Shell) expandPattern(w word) (string, error) {
+	e := &expander{sh: sh}
+	if err := e.word(w); err != nil {
+		return "", err
+	}
+	return e.pattern.String(), nil
+}
+
+// expandHeredoc раскрывает тело heredoc как строку в двойных кавычках:
+// обратный слеш экранирует только $, ` и \
+func (sh *Shell) expandHeredoc(text string) (string, error) {
+	w := make(word, 0)
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && strings.IndexByte("$`\\", text[i+1]) >= 0 {
+			w = append(w, wordPart{text[start:i], doubleQuoted}, wordPart{text[i+1 : i+2], singleQuoted})
+			i++
+			start = i + 1
+		}
+	}
+	w = append(w, wordPart{text[start:], doubleQuoted})
+	return sh.expandString(w)
+}
+
+type expander struct {
+	sh *Shell
+	// split - делить результат подстановок без кавычек на поля и раскрывать шаблоны путей
+	split  bool
+	fields []string
+	field  strings.Builder
+	// pattern - то же поле как шаблон, где символы из кавычек экранированы,
+	// glob - в поле есть *, ? или [ вне кавычек
+	pattern strings.Builder
+	glob    bool
+	inField bool
+}
+
+func (e *expander) word(w word) error {
+	for i, p := range w {
+		switch p.quote {
+		case singleQuoted:
+			e.literal(p.text, true)
+		case doubleQuoted:
+			if (p.text == "$@" || p.text == "${@}") && len(e.sh.positional) == 0 {
+				// "$@" без параметров не дает ни одного поля
+				continue
+			}
+			e.inField = true
+			if err := e.text(p.text, true); err != nil {
+				return err
+			}
+		default:
+			text := p.text
+			if i == 0 && strings.HasPrefix(text, "~") {
+				prefix, _, _ := strings.Cut(text, "/")
+				if home, ok := e.sh.tildeHome(prefix[1:]); ok {
+					e.literal(home, true)
+					text = text[len(prefix):]
+				}
+			}
+			if err := e.text(text, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// literal добавляет s к полю. Символы шаблонов из кавычек экранируются
+func (e *expander) literal(s string, quoted bool) {
+	e.field.WriteString(s)
+	for _, c := range s {
+		if c == '\\' || quoted && strings.ContainsRune("*?[]", c) {
+			e.pattern.WriteRune('\\')
+		} else if strings.ContainsRune("*?[", c) {
+			e.glob = true
+		}
+		e.pattern.WriteRune(c)
+	}
+	e.inField = true
+}
+
+func (e *expander) endField() {
+	if e.inField {
+		matches := []string(nil)
+		if e.split && e.glob {
+			matches = glob(e.sh.dir, e.pattern.String())
+		}
+		if len(matches) > 0 {
+			e.fields = append(e.fields, matches...)
+		} else {
+			e.fields = append(e.fields, e.field.String())
+		}
+	}
+	e.field.Reset()
+	e.pattern.Reset()
+	e.glob = false
+	e.inField = false
+}
+
+// text выполняет подстановки в куске слова
+func (e *expander) text(s string, quoted bool) error {
+	input := []rune(s)
+	for i := 0; i < len(input); {
+		switch input[i] {
+		case '$':
+			if n := allParams(input[i+1:]); n > 0 && quoted && e.split {
+				// "$@" раскрывается в отдельное поле для каждого параметра
+				for j, arg := range e.sh.positional {
+					if j > 0 {
+						e.inField = true
+						e.endField()
+					}
+					e.literal(arg, true)
+				}
+				i += 1 + n
+				continue
+			}
+			value, n, ok, err := e.parameter(input[i+1:])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				e.literal("$", quoted)
+				i++
+				continue
+			}
+			i += 1 + n
+			e.substitute(value, quoted)
+		case '`':
+			end, ok := closingBackquote(input, i)
+			if !ok {
+				e.literal("`", quoted)
+				i++
+				continue
+			}
+			value, err := e.sh.commandSubstitution(unescapeBackquote(string(input[i+1 : end])))
+			if err != nil {
+				return err
+			}
+			i = end + 1
+			e.substitute(value, quoted)
+		default:
+			e.literal(string(input[i]), quoted)
+			i++
+		}
+	}
+	return nil
+}
+
+// allParams возвращает длину @ или {@} в начале input, иначе 0
+func allParams(input []rune) int {
+	switch s := string(input); {
+	case strings.HasPrefix(s, "@"):
+		return 1
+	case strings.HasPrefix(s, "{@}"):
+		return 3
+	}
+	return 0
+}
+
+// substitute добавляет результат подстановки. Вне кавычек он делится на поля по символам $IFS
+func (e *expander) substitute(value string, quoted bool) {
+	if quoted || !e.split {
+		e.literal(value, true)
+		return
+	}
+	ifs, ok := e.sh.lookupVar("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	for _, c := range value {
+		if strings.ContainsRune(ifs, c) {
+			e.endField()
+			continue
+		}
+		e.literal(string(c), false)
+	}
+}
+
+// unescapeBackquote снимает экранирование \`, \$ и \\ внутри `...`
+func unescapeBackquote(s string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("`$\\", s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// tildeHome раскрывает ~ (домашний каталог), ~user, ~+ ($PWD) и ~- ($OLDPWD)
+func (sh *Shell) tildeHome(name string) (string, bool) {
+	switch name {
+	case "":
+		if home, ok := sh.lookupVar("HOME"); ok {
+			return home, true
+		}
+		u, err := user.Current()
+		if err != nil {
+			return "", false
+		}
+		return u.HomeDir, true
+	case "+":
+		return sh.lookupVar("PWD")
+	case "-":
+		return sh.lookupVar("OLDPWD")
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
+
+// parameter разбирает подстановку после $ и возвращает ее значение и длину.
+// ok = false, если за $ нет имени и $ остается обычным символом
+func (e *expander) parameter(input []rune) (string, int, bool, error) {
+	if len(input) == 0 {
+		return "", 0, false, nil
+	}
+	switch c := input[0]; {
+	case c == '(':
+		end, ok := matchingClose(input, 0)
+		if !ok {
+			return "", 0, false, fmt.Errorf("$%s: unterminated command substitution", string(input))
+		}
+		value, err := e.sh.commandSubstitution(string(input[1:end]))
+		return value, end + 1, true, err
+	case c == '{':
+		end, ok := matchingClose(input, 0)
+		if !ok {
+			return "", 0, false, fmt.Errorf("%s: bad substitution", string(input))
+		}
+		value, err := e.sh.braced(string(input[1:end]))
+		return value, end + 1, true, err
+	case isNameChar(c) && !(c >= '0' && c <= '9'):
+		n := 1
+		for n < len(input) && isNameChar(input[n]) {
+			n++
+		}
+		value, _ := e.sh.lookupVar(string(input[:n]))
+		return value, n, true, nil
+	case c >= '0' && c <= '9' || strings.ContainsRune("?$!#@*-", c):
+		value, _ := e.sh.lookupVar(string(c))
+		return value, 1, true, nil
+	}
+	return "", 0, false, nil
+}
+
+// braced раскрывает ${...}: ${#NAME}, ${NAME:-word}, ${NAME:=word}, ${NAME:?word}, ${NAME:+word}
+// (без двоеточия проверяется только, задана ли переменная), ${NAME#pattern}, ${NAME##pattern},
+// ${NAME%pattern} и ${NAME%%pattern}
+func (sh *Shell) braced(expr string) (string, error) {
+	badSubstitution := fmt.Errorf("${%s}: bad substitution", expr)
+	if len(expr) > 1 && expr[0] == '#' {
+		if value, ok := sh.lookupVar(expr[1:]); ok || isName(expr[1:]) {
+			return fmt.Sprint(utf8.RuneCountInString(value)), nil
+		}
+		return "", badSubstitution
+	}
+
+	n := 0
+	for n < len(expr) && isNameChar(rune(expr[n])) {
+		n++
+	}
+	if n == 0 && expr != "" && strings.ContainsRune("?$!#@*-", rune(expr[0])) {
+		n = 1
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" || (!isName(name) && n > 1 && !isDigits(name)) {
+		return "", badSubstitution
+	}
+	value, set := sh.lookupVar(name)
+	if rest == "" {
+		return value, nil
+	}
+
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+		set = set && value != ""
+	}
+	if rest == "" {
+		return "", badSubstitution
+	}
+	op, arg := rest[:1], rest[1:]
+	if !colon && (op == "#" || op == "%") && strings.HasPrefix(arg, op) {
+		op, arg = op+op, arg[1:]
+	}
+	switch op {
+	case "-", "=", "?", "+":
+	case "#", "##", "%", "%%":
+		if colon {
+			return "", badSubstitution
+		}
+	default:
+		return "", badSubstitution
+	}
+
+	word, err := sh.expandNested(arg)
+	if err != nil {
+		return "", err
+	}
+	switch op {
+	case "-":
+		if !set {
+			return word, nil
+		}
+	case "=":
+		if !set {
+			if !isName(name) {
+				return "", fmt.Errorf("$%s: cannot assign in this way", name)
+			}
+			sh.setVar(name, word)
+			return word, nil
+		}
+	case "?":
+		if !set {
+			if word == "" {
+				word = "parameter null or not set"
+			}
+			return "", unsetParameterError{name, word}
+		}
+	case "+":
+		if set {
+			return word, nil
+		}
+		return "", nil
+	case "#", "##":
+		return trimPrefixPattern(value, word, op == "##"), nil
+	case "%", "%%":
+		return trimSuffixPattern(value, word, op == "%%"), nil
+	}
+	return value, nil
+}
+
+// expandNested раскрывает слово внутри ${...}, в нем могут быть кавычки и пробелы
+func (sh *Shell) expandNested(text string) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	l := &lexer{input: []rune(text), nested: true}
+	t, err := l.word()
+	if err != nil {
+		return "", err
+	}
+	return sh.expandString(t.word)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// trimPrefixPattern удаляет самое короткое (или самое длинное) начало value, подходящее под pattern
+func trimPrefixPattern(value, pattern string, longest bool) string {
+	input := []rune(value)
+	for i := 0; i <= len(input); i++ {
+		n := i
+		if longest {
+			n = len(input) - i
+		}
+		if matchPattern(pattern, string(input[:n])) {
+			return string(input[n:])
+		}
+	}
+	return value
+}
+
+func trimSuffixPattern(value, pattern string, longest bool) string {
+	input := []rune(value)
+	for i := 0; i <= len(input); i++ {
+		n := len(input) - i
+		if longest {
+			n = i
+		}
+		if matchPattern(pattern, string(input[n:])) {
+			return string(input[:n])
+		}
+	}
+	return value
+}
+
+// matchPattern сопоставляет s с шаблоном шелла: * - любая строка, ? - любой символ,
+// [abc], [a-z] и [!abc] - символ из набора, \x - сам символ x
+func matchPattern(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	// при несовпадении после * откатываемся к ней и даем ей съесть еще один символ
+	starP, starS := -1, 0
+	i, j := 0, 0
+	for j < len(str) {
+		if i < len(p) {
+			switch p[i] {
+			case '*':
+				starP, starS = i, j
+				i++
+				continue
+			case '?':
+				i++
+				j++
+				continue
+			case '[':
+				if matched, next, ok := matchClass(p, i, str[j]); ok {
+					if matched {
+						i, j = next, j+1
+						continue
+					}
+				} else if str[j] == '[' {
+					i++
+					j++
+					continue
+				}
+			case '\\':
+				if i+1 < len(p) && p[i+1] == str[j] {
+					i += 2
+					j++
+					continue
+				}
+			default:
+				if p[i] == str[j] {
+					i++
+					j++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starS++
+		i, j = starP+1, starS
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// matchClass проверяет символ c по набору [...], начинающемуся в p[open].
+// ok = false, если у набора нет закрывающей скобки и [ - обычный символ
+func matchClass(p []rune, open int, c rune) (matched bool, next int, ok bool) {
+	i := open + 1
+	negate := i < len(p) && (p[i] == '!' || p[i] == '^')
+	if negate {
+		i++
+	}
+	for first := true; i < len(p); first = false {
+		if p[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			i += 2
+		}
+		if lo <= c && c <= hi {
+			matched = true
+		}
+		i++
+	}
+	return false, 0, false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// glob раскрывает шаблон пути по файловой системе. Шаблон сопоставляется по частям
+// между /, часть ** совпадает с любым числом вложенных каталогов. Файлы, имя которых
+// начинается с точки, находятся, только если часть шаблона тоже начинается с точки.
+// Относительный шаблон раскрывается от каталога dir
+func glob(dir, pattern string) []string {
+	segments := strings.Split(pattern, "/")
+	paths := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		paths, segments = []string{"/"}, segments[1:]
+	}
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		next := make([]string, 0)
+		for _, base := range paths {
+			next = append(next, globSegment(dir, base, segment, last)...)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		paths = next
+	}
+
+	seen := make(map[string]bool, len(paths))
+	matches := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !seen[path] && path != "" {
+			seen[path] = true
+			matches = append(matches, path)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func globSegment(dir, base, segment string, last bool) []string {
+	switch {
+	case segment == "":
+		// a//b или завершающий / - подходят только каталоги
+		if isDir(dir, base) {
+			return []string{strings.TrimSuffix(base, "/") + "/"}
+		}
+		return nil
+	case !hasGlobMeta(segment):
+		path := joinPath(base, unescapePattern(segment))
+		if _, err := os.Lstat(resolvePath(dir, path)); err != nil || (!last && !isDir(dir, path)) {
+			return nil
+		}
+		return []string{path}
+	case segment == "**":
+		return walkTree(dir, base, last)
+	}
+
+	entries, err := os.ReadDir(resolvePath(dir, dirOf(base)))
+	if err != nil {
+		return nil
+	}
+	matches := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(segment, ".") {
+			continue
+		}
+		path := joinPath(base, name)
+		if matchPattern(segment, name) && (last || isDir(dir, path)) {
+			matches = append(matches, path)
+		}
+	}
+	return matches
+}
+
+// walkTree возвращает каталоги под base вместе с самим base, а для последней
+// части шаблона - все файлы и каталоги под base. Скрытые пропускаются
+func walkTree(dir, base string, files bool) []string {
+	paths := make([]string, 0)
+	if !files {
+		paths = append(paths, base)
+	}
+	root := resolvePath(dir, dirOf(base))
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		// пути под root снова записываются от base, как их написали в шаблоне
+		rel, _ := filepath.Rel(root, path)
+		path = filepath.Join(dirOf(base), rel)
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if files || d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+func hasGlobMeta(segment string) bool {
+	for i := 0; i < len(segment); i++ {
+		switch segment[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+func unescapePattern(segment string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(segment); i++ {
+		if segment[i] == '\\' && i+1 < len(segment) {
+			i++
+		}
+		b.WriteByte(segment[i])
+	}
+	return b.String()
+}
+
+// joinPath приписывает name к base без очистки пути, чтобы совпадения выглядели как шаблон
+func joinPath(base, name string) string {
+	if base == "" {
+		return name
+	}
+	if strings.HasSuffix(base, "/") {
+		return base + name
+	}
+	return base + "/" + name
+}
+
+func dirOf(base string) string {
+	if base == "" {
+		return "."
+	}
+	return base
+}
+
+func isDir(dir, path string) bool {
+	info, err := os.Stat(resolvePath(dir, dirOf(path)))
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,19 @@
+module dev08
+
+go 1.21
+
+require (
+	dev03 v0.0.0
+	dev05 v0.0.0
+	dev06 v0.0.0
+	dev09 v0.0.0
+)
+
+require golang.org/x/net v0.17.0 // indirect
+
+replace (
+	dev03 => ../dev03
+	dev05 => ../dev05
+	dev06 => ../dev06
+	dev09 => ../dev09
+)
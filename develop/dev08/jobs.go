@@ -0,0 +1,546 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+type jobState int
+
+const (
+	jobRunning jobState = iota
+	jobStopped
+	jobDone
+)
+
+// process - стадия задания: внешний процесс или встроенная команда (pid = 0),
+// выполняющаяся в горутине
+type process struct {
+	pid    int
+	state  jobState
+	status int
+	// deadline - срок процесса, запущенного через timeout
+	deadline *deadline
+}
+
+// job - конвейер, запущенный шеллом. Пока задание выполняется на переднем плане,
+// у него нет номера; номер появляется, когда оно уходит в фон или останавливается
+type job struct {
+	id    int
+	pgid  int
+	cmd   string
+	procs []*process
+	// timers - замеры time, которым достается время завершившихся процессов задания
+	timers []*cpuTimer
+}
+
+// state - задание выполняется, пока выполняется хотя бы одна стадия,
+// и остановлено, если остальные стадии остановлены или завершились
+func (j *job) state() jobState {
+	state := jobDone
+	for _, p := range j.procs {
+		if p.state == jobRunning {
+			return jobRunning
+		}
+		if p.state == jobStopped {
+			state = jobStopped
+		}
+	}
+	return state
+}
+
+// status - код возврата последней стадии
+func (j *job) status() int {
+	if len(j.procs) == 0 {
+		return 0
+	}
+	return j.procs[len(j.procs)-1].status
+}
+
+func (j *job) stateString() string {
+	switch j.state() {
+	case jobRunning:
+		return "Running"
+	case jobStopped:
+		return "Stopped"
+	}
+	if status := j.status(); status != 0 {
+		return "Exit " + strconv.Itoa(status)
+	}
+	return "Done"
+}
+
+// signal отправляет sig всем процессам задания: всей группе, если она есть
+func (j *job) signal(sig syscall.Signal) error {
+	if j.pgid != 0 {
+		return syscall.Kill(-j.pgid, sig)
+	}
+	for _, p := range j.procs {
+		if p.pid != 0 && p.state != jobDone {
+			if err := syscall.Kill(p.pid, sig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jobTable - задания шелла. Состояние процессов меняет только reap, остальные
+// ждут изменений на cond
+type jobTable struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// list упорядочен по давности: последнее задание с номером - текущее (+), перед ним - предыдущее (-)
+	list []*job
+}
+
+func newJobTable() *jobTable {
+	t := &jobTable{}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// watched - таблицы заданий, процессы которых забирает обработчик SIGCHLD: по одной
+// на выполняющийся Shell и на Shell, который уже завершился, но оставил фоновые процессы
+var watched = struct {
+	sync.Mutex
+	tables map[*jobTable]bool
+}{tables: map[*jobTable]bool{}}
+
+func init() {
+	sigchld := make(chan os.Signal, 1)
+	signal.Notify(sigchld, syscall.SIGCHLD)
+	go func() {
+		for range sigchld {
+			reapWatched()
+		}
+	}()
+}
+
+// watchJobs передает процессы таблицы t обработчику SIGCHLD
+func watchJobs(t *jobTable) {
+	watched.Lock()
+	defer watched.Unlock()
+	watched.tables[t] = true
+}
+
+// unwatchJobs отмечает, что шелл таблицы t завершился. Обработчик SIGCHLD забирает
+// ее процессы, пока они не завершатся все
+func unwatchJobs(t *jobTable) {
+	watched.Lock()
+	defer watched.Unlock()
+	watched.tables[t] = false
+	if !t.reap() {
+		delete(watched.tables, t)
+	}
+}
+
+func reapWatched() {
+	watched.Lock()
+	defer watched.Unlock()
+	for t, running := range watched.tables {
+		if !t.reap() && !running {
+			delete(watched.tables, t)
+		}
+	}
+}
+
+func (t *jobTable) add(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.list = append(t.list, j)
+}
+
+func (t *jobTable) addProcess(j *job, p *process) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.procs = append(j.procs, p)
+}
+
+// finish отмечает завершение встроенной команды
+func (t *jobTable) finish(p *process, status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p.state, p.status = jobDone, status
+	t.cond.Broadcast()
+}
+
+// reap забирает изменения состояния дочерних процессов заданий и сообщает, остались ли
+// незавершенные процессы. Ждать приходится каждый pid отдельно: wait4(-1) забрал бы
+// и чужие дочерние процессы, например запущенные программой, в которую встроен Shell,
+// или другим Shell
+func (t *jobTable) reap() (pending bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.cond.Broadcast()
+	for _, j := range t.list {
+		for _, p := range j.procs {
+			for p.pid != 0 && p.state != jobDone {
+				var ws syscall.WaitStatus
+				var usage syscall.Rusage
+				pid, err := syscall.Wait4(p.pid, &ws, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, &usage)
+				if err == syscall.EINTR {
+					continue
+				}
+				if err != nil {
+					p.state, p.status = jobDone, 127
+					break
+				}
+				if pid == 0 {
+					break
+				}
+				switch {
+				case ws.Exited():
+					p.state, p.status = jobDone, ws.ExitStatus()
+				case ws.Signaled():
+					p.state, p.status = jobDone, 128+int(ws.Signal())
+				case ws.Stopped():
+					p.state, p.status = jobStopped, 128+int(ws.StopSignal())
+				case ws.Continued():
+					p.state = jobRunning
+				}
+				if p.state == jobDone {
+					p.status = p.deadline.finish(p.status)
+					for _, timer := range j.timers {
+						timer.add(&usage)
+					}
+				}
+			}
+			pending = pending || p.pid != 0 && p.state != jobDone
+		}
+	}
+	return pending
+}
+
+// wait ждет, пока задание завершится или остановится
+func (t *jobTable) wait(j *job) (jobState, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for j.state() == jobRunning {
+		t.cond.Wait()
+	}
+	return j.state(), j.status()
+}
+
+func (t *jobTable) remove(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, other := range t.list {
+		if other == j {
+			t.list = append(t.list[:i], t.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// setCurrent дает заданию номер, если его еще нет, и делает его текущим
+func (t *jobTable) setCurrent(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if j.id == 0 {
+		for _, other := range t.list {
+			j.id = max(j.id, other.id)
+		}
+		j.id++
+	}
+	for i, other := range t.list {
+		if other == j {
+			t.list = append(t.list[:i], t.list[i+1:]...)
+			break
+		}
+	}
+	t.list = append(t.list, j)
+}
+
+// continueJob возобновляет остановленное задание
+func (t *jobTable) continueJob(j *job) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := j.signal(syscall.SIGCONT); err != nil {
+		return err
+	}
+	for _, p := range j.procs {
+		if p.state == jobStopped {
+			p.state = jobRunning
+		}
+	}
+	return nil
+}
+
+// signalJob отправляет сигнал заданию. Остановленное задание не обработает SIGTERM
+// и SIGHUP, пока стоит, поэтому вслед за ними, как в bash, посылается SIGCONT
+func (t *jobTable) signalJob(j *job, sig syscall.Signal) error {
+	t.mu.Lock()
+	stopped := j.state() == jobStopped
+	err := j.signal(sig)
+	t.mu.Unlock()
+	if err != nil || !stopped || (sig != syscall.SIGTERM && sig != syscall.SIGHUP) {
+		return err
+	}
+	return t.continueJob(j)
+}
+
+// numbered возвращает задания с номерами и их пометки + и -
+func (t *jobTable) numbered() ([]*job, map[*job]byte) {
+	list := make([]*job, 0, len(t.list))
+	for _, j := range t.list {
+		if j.id != 0 {
+			list = append(list, j)
+		}
+	}
+	marks := make(map[*job]byte, len(list))
+	for i, j := range list {
+		marks[j] = ' '
+		if i == len(list)-1 {
+			marks[j] = '+'
+		} else if i == len(list)-2 {
+			marks[j] = '-'
+		}
+	}
+	return list, marks
+}
+
+// report печатает задания в формате jobs и удаляет из таблицы завершенные.
+// Если onlyDone, печатаются только завершенные - так шелл сообщает о них перед приглашением
+func (t *jobTable) report(w io.Writer, onlyDone bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list, marks := t.numbered()
+	for _, j := range list {
+		if j.state() == jobDone || !onlyDone {
+			fmt.Fprintf(w, "[%d]%c  %-24s%s\n", j.id, marks[j], j.stateString(), j.cmd)
+		}
+	}
+	t.removeDone()
+}
+
+// removeDone удаляет из таблицы завершенные фоновые задания
+func (t *jobTable) removeDone() {
+	list := t.list[:0]
+	for _, j := range t.list {
+		if j.id == 0 || j.state() != jobDone {
+			list = append(list, j)
+		}
+	}
+	t.list = list
+}
+
+var errNoSuchJob = errors.New("no such job")
+
+// find находит задание по спецификации: %n, %+, %%, %- или %prefix для начала команды,
+// а без % - по pid одного из процессов
+func (t *jobTable) find(spec string) (*job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list, marks := t.numbered()
+	if !strings.HasPrefix(spec, "%") {
+		pid, err := strconv.Atoi(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: not a pid or valid job spec", spec)
+		}
+		for _, j := range t.list {
+			for _, p := range j.procs {
+				if p.pid == pid {
+					return j, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("pid %d is not a child of this shell", pid)
+	}
+
+	spec = spec[1:]
+	for _, j := range list {
+		switch {
+		case (spec == "" || spec == "%" || spec == "+") && marks[j] == '+',
+			spec == "-" && marks[j] == '-',
+			spec == strconv.Itoa(j.id):
+			return j, nil
+		}
+	}
+	if _, err := strconv.Atoi(spec); err != nil && spec != "" && spec != "%" && spec != "+" && spec != "-" {
+		for i := len(list) - 1; i >= 0; i-- {
+			if strings.HasPrefix(list[i].cmd, spec) {
+				return list[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%%%s: %w", spec, errNoSuchJob)
+}
+
+// terminal - управляющий терминал интерактивного шелла. enabled = false, если
+// stdin не терминал: тогда управления заданиями нет, как в неинтерактивном sh
+type terminal struct {
+	enabled bool
+	// interactive в отличие от enabled не выключается в подоболочках: Ctrl+C
+	// прерывает и подоболочку, выполняемую в самом шелле
+	interactive bool
+	fd          int
+	pgid        int
+	termios     syscall.Termios
+}
+
+// initJobControl переводит шелл в собственную группу процессов и делает ее
+// группой переднего плана. Сигналы с клавиатуры шелл перехватывает, чтобы Ctrl+C
+// и Ctrl+Z доставались только заданию переднего плана. Обработчики, а не SIG_IGN,
+// нужны потому, что игнорирование сигналов наследуется запущенными программами
+func (sh *Shell) initJobControl() {
+	fd := int(sh.stdin.Fd())
+	if ioctl(fd, syscall.TCGETS, unsafe.Pointer(&sh.terminal.termios)) != nil {
+		return
+	}
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP, syscall.SIGTTIN)
+
+	pid := os.Getpid()
+	if syscall.Getpgrp() != pid {
+		if err := syscall.Setpgid(0, 0); err != nil {
+			fmt.Fprintln(sh.stderr, "job control disabled:", err)
+			return
+		}
+	}
+	if err := tcsetpgrp(fd, pid); err != nil {
+		fmt.Fprintln(sh.stderr, "job control disabled:", err)
+		return
+	}
+	sh.terminal.enabled, sh.terminal.interactive, sh.terminal.fd, sh.terminal.pgid = true, true, fd, pid
+}
+
+// reclaimTerminal возвращает терминал шеллу после задания переднего плана
+// и восстанавливает режим терминала, который задание могло изменить
+func (sh *Shell) reclaimTerminal() {
+	if !sh.terminal.enabled {
+		return
+	}
+	if err := tcsetpgrp(sh.terminal.fd, sh.terminal.pgid); err != nil {
+		fmt.Fprintln(sh.stderr, err)
+	}
+	ioctl(sh.terminal.fd, syscall.TCSETS, unsafe.Pointer(&sh.terminal.termios))
+}
+
+// tcsetpgrp делает pgid группой переднего плана терминала fd. Шелл вызывает
+// ее и из фоновой группы, поэтому на время вызова SIGTTOU блокируется - иначе
+// ядро остановило бы шелл
+func tcsetpgrp(fd, pgid int) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	const sigBlock, sigSetmask = 0, 2
+	block := uint64(1) << (uint(syscall.SIGTTOU) - 1)
+	var old uint64
+	syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigBlock, uintptr(unsafe.Pointer(&block)), uintptr(unsafe.Pointer(&old)), 8, 0, 0)
+	defer syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, sigSetmask, uintptr(unsafe.Pointer(&old)), 0, 8, 0, 0)
+
+	p := int32(pgid)
+	return ioctl(fd, syscall.TIOCSPGRP, unsafe.Pointer(&p))
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// foreground ждет задание переднего плана. Остановленное задание остается
+// в таблице с номером, завершенное удаляется
+func (sh *Shell) foreground(j *job) int {
+	state, status := sh.jobs.wait(j)
+	sh.reclaimTerminal()
+	if state == jobStopped {
+		sh.jobs.setCurrent(j)
+		fmt.Fprintf(sh.stderr, "\n[%d]+  %-24s%s\n", j.id, "Stopped", j.cmd)
+		return status
+	}
+	sh.jobs.remove(j)
+	return status
+}
+
+func (sh *Shell) jobsCommand(args []string, std stdio) int {
+	sh.jobs.reap()
+	sh.jobs.report(std.out, false)
+	return 0
+}
+
+// fgCommand продолжает задание на переднем плане, отдавая ему терминал
+func (sh *Shell) fgCommand(args []string, std stdio) int {
+	j, err := sh.jobs.find(jobSpec(args))
+	if err != nil {
+		fmt.Fprintln(std.err, "fg:", err)
+		return 1
+	}
+	fmt.Fprintln(std.out, j.cmd)
+	if sh.terminal.enabled && j.pgid != 0 {
+		if err = tcsetpgrp(sh.terminal.fd, j.pgid); err != nil {
+			fmt.Fprintln(std.err, "fg:", err)
+			return 1
+		}
+	}
+	if err = sh.jobs.continueJob(j); err != nil {
+		sh.reclaimTerminal()
+		fmt.Fprintln(std.err, "fg:", err)
+		return 1
+	}
+	return sh.foreground(j)
+}
+
+// bgCommand продолжает остановленное задание в фоне
+func (sh *Shell) bgCommand(args []string, std stdio) int {
+	j, err := sh.jobs.find(jobSpec(args))
+	if err != nil {
+		fmt.Fprintln(std.err, "bg:", err)
+		return 1
+	}
+	if err = sh.jobs.continueJob(j); err != nil {
+		fmt.Fprintln(std.err, "bg:", err)
+		return 1
+	}
+	sh.jobs.setCurrent(j)
+	fmt.Fprintf(std.out, "[%d]+ %s &\n", j.id, j.cmd)
+	return 0
+}
+
+// waitCommand ждет завершения перечисленных заданий или всех фоновых,
+// код возврата - код последнего из них
+func (sh *Shell) waitCommand(args []string, std stdio) int {
+	var waiting []*job
+	status := 0
+	if len(args) == 0 {
+		sh.jobs.mu.Lock()
+		waiting = append(waiting, sh.jobs.list...)
+		sh.jobs.mu.Unlock()
+	}
+	for _, arg := range args {
+		j, err := sh.jobs.find(arg)
+		if err != nil {
+			fmt.Fprintln(std.err, "wait:", err)
+			status = 127
+			continue
+		}
+		waiting = append(waiting, j)
+	}
+	for _, j := range waiting {
+		state, jobStatus := sh.jobs.wait(j)
+		if state == jobDone && j.id != 0 {
+			sh.jobs.remove(j)
+		}
+		if len(args) > 0 {
+			status = jobStatus
+		}
+	}
+	return status
+}
+
+func jobSpec(args []string) string {
+	if len(args) == 0 {
+		return "%+"
+	}
+	return args[0]
+}
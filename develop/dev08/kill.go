@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames - имена сигналов Linux без префикса SIG, индекс - номер сигнала
+var signalNames = []string{1: "HUP", "INT", "QUIT", "ILL", "TRAP", "ABRT", "BUS", "FPE", "KILL", "USR1",
+	"SEGV", "USR2", "PIPE", "ALRM", "TERM", "STKFLT", "CHLD", "CONT", "STOP", "TSTP", "TTIN", "TTOU",
+	"URG", "XCPU", "XFSZ", "VTALRM", "PROF", "WINCH", "IO", "PWR", "SYS"}
+
+// parseSignal разбирает сигнал по номеру или имени: 9, KILL, SIGKILL, kill
+func parseSignal(spec string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 0 || n >= len(signalNames) {
+			return 0, fmt.Errorf("%s: invalid signal specification", spec)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	for n, other := range signalNames {
+		if n > 0 && other == name {
+			return syscall.Signal(n), nil
+		}
+	}
+	return 0, fmt.Errorf("%s: invalid signal specification", spec)
+}
+
+// listSignals - kill -l: без аргументов печатает таблицу сигналов, иначе переводит
+// номер в имя и обратно. Код возврата больше 128 переводится в сигнал, который его дал
+func listSignals(args []string, std stdio) int {
+	if len(args) == 0 {
+		for n := 1; n < len(signalNames); n++ {
+			sep := "\t"
+			if n%5 == 0 || n == len(signalNames)-1 {
+				sep = "\n"
+			}
+			fmt.Fprintf(std.out, "%2d) SIG%s%s", n, signalNames[n], sep)
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n > 128 {
+				n -= 128
+			}
+			if n > 0 && n < len(signalNames) {
+				fmt.Fprintln(std.out, signalNames[n])
+				continue
+			}
+		} else if sig, err := parseSignal(arg); err == nil {
+			fmt.Fprintln(std.out, int(sig))
+			continue
+		}
+		fmt.Fprintf(std.err, "kill: %s: invalid signal specification\n", arg)
+		status = 1
+	}
+	return status
+}
+
+// kill [-s sig | -n num | -sig] pid | -pgid | %job ... - отправляет сигнал, по умолчанию SIGTERM.
+// Ошибка в одном аргументе не мешает остальным, код возврата тогда 1
+func (sh *Shell) kill(args []string, std stdio) int {
+	sig := syscall.SIGTERM
+	if len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		var spec string
+		switch args[0] {
+		case "-l", "-L":
+			return listSignals(args[1:], std)
+		case "-s", "-n":
+			if len(args) < 2 {
+				fmt.Fprintf(std.err, "kill: %s: option requires an argument\n", args[0])
+				return 2
+			}
+			spec, args = args[1], args[2:]
+		case "--":
+			args = args[1:]
+		default:
+			spec, args = args[0][1:], args[1:]
+		}
+		if spec != "" {
+			var err error
+			if sig, err = parseSignal(spec); err != nil {
+				fmt.Fprintln(std.err, "kill:", err)
+				return 1
+			}
+		}
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(std.err, "kill: usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]")
+		return 2
+	}
+
+	status := 0
+	for _, arg := range args {
+		if err := sh.signalTarget(arg, sig); err != nil {
+			fmt.Fprintln(std.err, "kill:", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// signalTarget отправляет sig процессу, группе процессов (-pgid) или заданию (%job)
+func (sh *Shell) signalTarget(target string, sig syscall.Signal) error {
+	if strings.HasPrefix(target, "%") {
+		j, err := sh.jobs.find(target)
+		if err != nil {
+			return err
+		}
+		if err = sh.jobs.signalJob(j, sig); err != nil {
+			return fmt.Errorf("%s: %v", target, err)
+		}
+		return nil
+	}
+	pid, err := strconv.Atoi(target)
+	if err != nil {
+		return fmt.Errorf("%s: arguments must be process or job IDs", target)
+	}
+	if err = syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("(%d) - %v", pid, err)
+	}
+	return nil
+}
+
+// pkill [-sig] [-x] [-f] pattern - отправляет сигнал процессам, имя которых совпадает
+// с регулярным выражением: с -x - целиком, с -f - проверяется вся командная строка
+func (sh *Shell) pkill(args []string, std stdio) int {
+	sig := syscall.SIGTERM
+	exact, full := false, false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		switch arg {
+		case "-x":
+			exact = true
+		case "-f":
+			full = true
+		default:
+			var err error
+			if sig, err = parseSignal(arg[1:]); err != nil {
+				fmt.Fprintln(std.err, "pkill:", err)
+				return 2
+			}
+		}
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(std.err, "pkill: usage: pkill [-signal] [-x] [-f] pattern")
+		return 2
+	}
+	pattern := args[0]
+	if exact {
+		pattern = "^(?:" + pattern + ")$"
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Fprintln(std.err, "pkill:", err)
+		return 2
+	}
+	procs, err := listProcs()
+	if err != nil {
+		fmt.Fprintln(std.err, "pkill:", err)
+		return 3
+	}
+
+	matched := false
+	for _, p := range procs {
+		name := p.comm
+		if full {
+			name = p.command()
+		}
+		if p.pid == os.Getpid() || !re.MatchString(name) {
+			continue
+		}
+		matched = true
+		if err := syscall.Kill(p.pid, sig); err != nil {
+			fmt.Fprintf(std.err, "pkill: killing pid %d failed: %v\n", p.pid, err)
+		}
+	}
+	if !matched {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokNewline
+	tokSemi // ;
+	tokPipe // |
+	tokAnd  // &&
+	tokOr   // ||
+	tokAmp  // &
+	tokDSemi
+	tokLParen
+	tokRParen
+	tokRedirect
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:     "end of input",
+	tokNewline: "newline",
+	tokSemi:    ";",
+	tokPipe:    "|",
+	tokAnd:     "&&",
+	tokOr:      "||",
+	tokAmp:     "&",
+	tokDSemi:   ";;",
+	tokLParen:  "(",
+	tokRParen:  ")",
+}
+
+func (k tokenKind) String() string {
+	if name, ok := tokenNames[k]; ok {
+		return name
+	}
+	return "word"
+}
+
+type quoteKind int
+
+const (
+	unquoted quoteKind = iota
+	// singleQuoted - текст в '...' и экранированные символы, берется как есть
+	singleQuoted
+	doubleQuoted
+)
+
+// wordPart - кусок слова с одинаковым способом кавычек: "a"'b'c - это три куска
+type wordPart struct {
+	text  string
+	quote quoteKind
+}
+
+type word []wordPart
+
+// String возвращает слово без кавычек
+func (w word) String() string {
+	b := &strings.Builder{}
+	for _, p := range w {
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// source возвращает слово с кавычками, из которого лексер соберет его снова
+func (w word) source() string {
+	b := &strings.Builder{}
+	for _, p := range w {
+		switch p.quote {
+		case singleQuoted:
+			b.WriteString("'" + strings.ReplaceAll(p.text, "'", `'\''`) + "'")
+		case doubleQuoted:
+			b.WriteString(`"` + p.text + `"`)
+		default:
+			b.WriteString(p.text)
+		}
+	}
+	return b.String()
+}
+
+// isQuoted сообщает, что в слове есть кавычки или экранирование
+func (w word) isQuoted() bool {
+	for _, p := range w {
+		if p.quote != unquoted {
+			return true
+		}
+	}
+	return false
+}
+
+// redirect - перенаправление ввода-вывода команды
+type redirect struct {
+	fd int
+	// op - один из >, >>, <, >&, <&, &>, &>>, <<, <<-, <<<
+	op string
+	// target - файл, номер дескриптора для >& и <&, строка для <<< или разделитель heredoc
+	target word
+	// heredoc - тело документа для << и <<-, expand - раскрывать ли в нем $-подстановки
+	heredoc string
+	expand  bool
+}
+
+type token struct {
+	kind  tokenKind
+	word  word
+	redir *redirect
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokRedirect:
+		return t.redir.op
+	case tokWord:
+		return t.word.source()
+	}
+	return t.kind.String()
+}
+
+// errIncomplete - ввод оборвался внутри кавычек или после оператора,
+// в интерактивном режиме нужно дочитать следующую строку
+var errIncomplete = errors.New("unexpected end of input")
+
+type lexer struct {
+	input []rune
+	pos   int
+	// nested - слово внутри ${...}: пробелы и операторы в нем не разделяют слова
+	nested bool
+	// heredocs - документы, тела которых начнутся со следующей строки
+	heredocs []*redirect
+}
+
+// lex разбивает ввод на слова и операторы
+func lex(input string) ([]token, error) {
+	l := &lexer{input: []rune(input)}
+	tokens := make([]token, 0)
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset < len(l.input) {
+		return l.input[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipBlanks(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.input) {
+		if len(l.heredocs) > 0 {
+			return token{}, errIncomplete
+		}
+		return token{kind: tokEOF}, nil
+	}
+
+	if t, ok, err := l.redirect(); ok || err != nil {
+		return t, err
+	}
+	switch c := l.peek(0); {
+	case c == '\n':
+		l.pos++
+		if err := l.readHeredocs(); err != nil {
+			return token{}, err
+		}
+		return token{kind: tokNewline}, nil
+	case c == ';' && l.peek(1) == ';':
+		l.pos += 2
+		return token{kind: tokDSemi}, nil
+	case c == ';':
+		l.pos++
+		return token{kind: tokSemi}, nil
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen}, nil
+	case c == '|' && l.peek(1) == '|':
+		l.pos += 2
+		return token{kind: tokOr}, nil
+	case c == '|':
+		l.pos++
+		return token{kind: tokPipe}, nil
+	case c == '&' && l.peek(1) == '&':
+		l.pos += 2
+		return token{kind: tokAnd}, nil
+	case c == '&':
+		l.pos++
+		return token{kind: tokAmp}, nil
+	}
+	return l.word()
+}
+
+// skipBlanks пропускает пробелы, продолжения строк и комментарии. Продолжение
+// в конце ввода означает, что команда продолжится на следующей строке
+func (l *lexer) skipBlanks() error {
+	for l.pos < len(l.input) {
+		switch c := l.peek(0); {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '\\' && l.peek(1) == '\n':
+			l.pos += 2
+			if l.pos == len(l.input) {
+				return errIncomplete
+			}
+		case c == '#':
+			for l.pos < len(l.input) && l.peek(0) != '\n' {
+				l.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isMeta(c rune) bool {
+	return strings.ContainsRune(" \t\r\n;|&<>()", c)
+}
+
+// redirectOps - операторы перенаправления, более длинные раньше своих префиксов
+var redirectOps = []string{"&>>", "&>", "<<<", "<<-", "<<", "<&", "<", ">>", ">&", ">"}
+
+// redirect читает оператор перенаправления с необязательным номером дескриптора
+// перед ним, например 2> или 2>&. ok = false, если на текущей позиции его нет
+func (l *lexer) redirect() (token, bool, error) {
+	end := l.pos
+	for end < len(l.input) && l.input[end] >= '0' && l.input[end] <= '9' {
+		end++
+	}
+	rest := string(l.input[end:min(end+3, len(l.input))])
+	op := ""
+	for _, candidate := range redirectOps {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" || (end > l.pos && op[0] == '&') {
+		return token{}, false, nil
+	}
+
+	r := &redirect{fd: 1, op: op}
+	if op[0] == '<' {
+		r.fd = 0
+	}
+	if end > l.pos {
+		fd, err := strconv.Atoi(string(l.input[l.pos:end]))
+		if err != nil {
+			return token{}, false, err
+		}
+		r.fd = fd
+	}
+	l.pos = end + len(op)
+
+	if op == "<<" || op == "<<-" {
+		if err := l.skipBlanks(); err != nil {
+			return token{}, false, err
+		}
+		if l.pos >= len(l.input) || isMeta(l.peek(0)) {
+			near, err := l.next()
+			if err != nil {
+				return token{}, false, err
+			}
+			return token{}, false, syntaxError{near}
+		}
+		delimiter, err := l.word()
+		if err != nil {
+			return token{}, false, err
+		}
+		r.target = delimiter.word
+		r.expand = !delimiter.word.isQuoted()
+		l.heredocs = append(l.heredocs, r)
+	}
+	return token{kind: tokRedirect, redir: r}, true, nil
+}
+
+// readHeredocs читает тела отложенных heredoc-документов: строки до строки-разделителя.
+// Для <<- у строк срезаются ведущие табуляции
+func (l *lexer) readHeredocs() error {
+	for len(l.heredocs) > 0 {
+		r := l.heredocs[0]
+		delimiter := r.target.String()
+		body := &strings.Builder{}
+		for {
+			if l.pos >= len(l.input) {
+				return errIncomplete
+			}
+			end := l.pos
+			for end < len(l.input) && l.input[end] != '\n' {
+				end++
+			}
+			line := string(l.input[l.pos:end])
+			if r.op == "<<-" {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if end >= len(l.input) && line != delimiter {
+				return errIncomplete
+			}
+			l.pos = min(end+1, len(l.input))
+			if line == delimiter {
+				break
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+		r.heredoc = body.String()
+		l.heredocs = l.heredocs[1:]
+	}
+	return nil
+}
+
+// word читает слово до пробела или оператора, собирая куски в кавычках
+func (l *lexer) word() (token, error) {
+	w := make(word, 0, 1)
+	text := &strings.Builder{}
+	flush := func() {
+		if text.Len() > 0 {
+			w = append(w, wordPart{text.String(), unquoted})
+			text.Reset()
+		}
+	}
+
+	for l.pos < len(l.input) && (l.nested || !isMeta(l.peek(0))) {
+		switch c := l.peek(0); c {
+		case '\\':
+			if l.pos+1 >= len(l.input) {
+				return token{}, errIncomplete
+			}
+			if l.peek(1) == '\n' {
+				l.pos += 2
+				if l.pos == len(l.input) {
+					return token{}, errIncomplete
+				}
+				continue
+			}
+			flush()
+			w = append(w, wordPart{string(l.peek(1)), singleQuoted})
+			l.pos += 2
+		case '\'':
+			flush()
+			end := l.pos + 1
+			for end < len(l.input) && l.input[end] != '\'' {
+				end++
+			}
+			if end >= len(l.input) {
+				return token{}, errIncomplete
+			}
+			w = append(w, wordPart{string(l.input[l.pos+1 : end]), singleQuoted})
+			l.pos = end + 1
+		case '"':
+			flush()
+			parts, err := l.doubleQuoted()
+			if err != nil {
+				return token{}, err
+			}
+			w = append(w, parts...)
+		case '$':
+			if err := l.dollar(text); err != nil {
+				return token{}, err
+			}
+		case '`':
+			if err := l.backquote(text); err != nil {
+				return token{}, err
+			}
+		default:
+			text.WriteRune(c)
+			l.pos++
+		}
+	}
+	flush()
+	return token{kind: tokWord, word: w}, nil
+}
+
+// dollar переносит в text подстановку ${...} или $(...) целиком, вместе с пробелами
+// и кавычками внутри: ее разберет раскрытие. Одиночный $ переносится как есть
+func (l *lexer) dollar(text *strings.Builder) error {
+	if next := l.peek(1); next != '{' && next != '(' {
+		text.WriteRune('$')
+		l.pos++
+		return nil
+	}
+	end, ok := matchingClose(l.input, l.pos+1)
+	if !ok {
+		return errIncomplete
+	}
+	text.WriteString(string(l.input[l.pos : end+1]))
+	l.pos = end + 1
+	return nil
+}
+
+// backquote переносит в text подстановку `...` целиком
+func (l *lexer) backquote(text *strings.Builder) error {
+	end, ok := closingBackquote(l.input, l.pos)
+	if !ok {
+		return errIncomplete
+	}
+	text.WriteString(string(l.input[l.pos : end+1]))
+	l.pos = end + 1
+	return nil
+}
+
+// closingBackquote находит `, закрывающую открытую на позиции open
+func closingBackquote(input []rune, open int) (int, bool) {
+	for i := open + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '`':
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// matchingClose находит скобку, закрывающую { или ( на позиции open, пропуская
+// вложенные скобки, кавычки и экранированные символы
+func matchingClose(input []rune, open int) (int, bool) {
+	opening := input[open]
+	closing := '}'
+	if opening == '(' {
+		closing = ')'
+	}
+	depth := 0
+	for i := open; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '\'':
+			for i++; i < len(input) && input[i] != '\''; i++ {
+			}
+		case '"':
+			for i++; i < len(input) && input[i] != '"'; i++ {
+				if input[i] == '\\' {
+					i++
+				}
+			}
+		case opening:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// doubleQuoted читает строку в двойных кавычках. Внутри них обратный слеш
+// экранирует только $, `, ", \ и перевод строки
+func (l *lexer) doubleQuoted() (word, error) {
+	l.pos++
+	parts := make(word, 0, 1)
+	text := &strings.Builder{}
+	// пустые "" тоже дают кусок слова, чтобы "" стало пустым аргументом
+	quotedEmpty := true
+	flush := func() {
+		if text.Len() > 0 || quotedEmpty {
+			parts = append(parts, wordPart{text.String(), doubleQuoted})
+			text.Reset()
+			quotedEmpty = false
+		}
+	}
+
+	for {
+		if l.pos >= len(l.input) {
+			return nil, errIncomplete
+		}
+		c := l.peek(0)
+		switch {
+		case c == '"':
+			l.pos++
+			flush()
+			return parts, nil
+		case c == '\\' && l.peek(1) == '\n':
+			l.pos += 2
+		case c == '\\' && strings.ContainsRune("$`\"\\", l.peek(1)):
+			quotedEmpty = false
+			flush()
+			parts = append(parts, wordPart{string(l.peek(1)), singleQuoted})
+			l.pos += 2
+		case c == '$':
+			quotedEmpty = false
+			if err := l.dollar(text); err != nil {
+				return nil, err
+			}
+		case c == '`':
+			quotedEmpty = false
+			if err := l.backquote(text); err != nil {
+				return nil, err
+			}
+		default:
+			quotedEmpty = false
+			text.WriteRune(c)
+			l.pos++
+		}
+	}
+}
+
+type syntaxError struct {
+	near token
+}
+
+func (e syntaxError) Error() string {
+	return fmt.Sprintf("syntax error near unexpected token `%s'", e.near)
+}
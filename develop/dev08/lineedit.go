@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// lineReader читает строки ввода, печатая перед каждой приглашение
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// errInterrupted - строка отменена по Ctrl+C
+var errInterrupted = errors.New("interrupted")
+
+// plainReader читает строки как есть: так шелл работает, когда stdin не терминал.
+// Приглашение печатается // в out. quiet - не печатать приглашение, так читаются скрипты
+type plainReader struct {
+	in    *bufio.Reader
+	out   io.Writer
+	quiet bool
+}
+
+func (r plainReader) readLine(prompt string) (string, error) {
+	if !r.quiet {
+		prompt, _ = visiblePrompt(prompt)
+		fmt.Fprint(r.out, prompt)
+	}
+	return r.in.ReadString('\n')
+}
+
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, syscall.TCGETS, unsafe.Pointer(&termios)) == nil
+}
+
+// editor - редактор строки в raw-режиме терминала: курсор, история, поиск по Ctrl+R
+// и дополнение по Tab. completions возвращает варианты дополнения слова перед курсором
+type editor struct {
+	fd          int
+	out         io.Writer
+	history     *history
+	completions func(line []rune, pos int) (int, []string)
+
+	prompt  string
+	line    []rune
+	pos     int
+	lastTab bool
+	// histPos - позиция в истории при листании стрелками, draft - строка, набранная до листания
+	histPos int
+	draft   []rune
+}
+
+func newEditor(fd int, out io.Writer, h *history, completions func([]rune, int) (int, []string)) *editor {
+	return &editor{fd: fd, out: out, history: h, completions: completions}
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+
+	// стрелки и другие клавиши, приходящие escape-последовательностями
+	keyUp rune = 0x110000 + iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+func (e *editor) readLine(prompt string) (string, error) {
+	var cooked syscall.Termios
+	if err := ioctl(e.fd, syscall.TCGETS, unsafe.Pointer(&cooked)); err != nil {
+		return "", err
+	}
+	raw := cooked
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN], raw.Cc[syscall.VTIME] = 1, 0
+	if err := ioctl(e.fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return "", err
+	}
+	defer ioctl(e.fd, syscall.TCSETS, unsafe.Pointer(&cooked))
+
+	e.prompt, e.line, e.pos, e.lastTab = prompt, nil, 0, false
+	e.histPos, e.draft = len(e.history.entries), nil
+	e.refresh()
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		tab := key == keyTab
+		switch key {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(e.line)
+			e.history.add(line)
+			return line + "\n", nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyTab:
+			e.complete()
+		case keyCtrlR:
+			if submit := e.search(); submit {
+				fmt.Fprint(e.out, "\r\n")
+				line := string(e.line)
+				e.history.add(line)
+				return line + "\n", nil
+			}
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyDelete:
+			e.deleteAt(e.pos)
+		case keyLeft, keyCtrlB:
+			e.pos = max(e.pos-1, 0)
+		case keyRight, keyCtrlF:
+			e.pos = min(e.pos+1, len(e.line))
+		case keyHome, keyCtrlA:
+			e.pos = 0
+		case keyEnd, keyCtrlE:
+			e.pos = len(e.line)
+		case keyUp, keyCtrlP:
+			e.browse(-1)
+		case keyDown, keyCtrlN:
+			e.browse(1)
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = append([]rune{}, e.line[e.pos:]...)
+			e.pos = 0
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		default:
+			if key >= ' ' && key < keyUp {
+				e.insert(string(key))
+			}
+		}
+		e.lastTab = tab
+		e.refresh()
+	}
+}
+
+// refresh перерисовывает строку приглашения и ставит курсор на место
+func (e *editor) refresh() {
+	prompt := e.prompt
+	if i := strings.LastIndexByte(prompt, '\n'); i >= 0 {
+		prompt = prompt[i+1:]
+	}
+	prompt, width := visiblePrompt(prompt)
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", prompt, string(e.line))
+	if column := width + e.pos; column > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", column)
+	}
+}
+
+func (e *editor) insert(s string) {
+	runes := []rune(s)
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.pos]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.pos:]...)
+	e.pos += len(runes)
+}
+
+func (e *editor) deleteAt(pos int) {
+	if pos < len(e.line) {
+		e.line = append(e.line[:pos], e.line[pos+1:]...)
+	}
+}
+
+// browse листает историю: -1 - к более старым записям, 1 - к более новым
+func (e *editor) browse(step int) {
+	next := e.histPos + step
+	if next < 0 || next > len(e.history.entries) {
+		return
+	}
+	if e.histPos == len(e.history.entries) {
+		e.draft = e.line
+	}
+	e.histPos = next
+	if next == len(e.history.entries) {
+		e.line = e.draft
+	} else {
+		e.line = []rune(e.history.entries[next])
+	}
+	e.pos = len(e.line)
+}
+
+// search - обратный поиск по истории. Набранный текст ищется от новых записей
+// к старым, повторный Ctrl+R ищет следующее совпадение. Enter выполняет найденную
+// строку, Ctrl+G и Ctrl+C возвращают исходную, другие клавиши оставляют найденную
+// для редактирования
+func (e *editor) search() (submit bool) {
+	original, originalPos := e.line, e.pos
+	query := []rune{}
+	from := len(e.history.entries) - 1
+	match := -1
+	find := func(start int) {
+		for i := min(start, len(e.history.entries)-1); i >= 0; i-- {
+			if strings.Contains(e.history.entries[i], string(query)) {
+				match = i
+				e.line = []rune(e.history.entries[i])
+				e.pos = strings.Index(e.history.entries[i], string(query))
+				e.pos = utf8.RuneCountInString(e.history.entries[i][:e.pos])
+				return
+			}
+		}
+	}
+	for {
+		status := "reverse-i-search"
+		if match < 0 && len(query) > 0 {
+			status = "failed reverse-i-search"
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), string(e.line))
+
+		key, err := e.readKey()
+		if err != nil {
+			return false
+		}
+		switch {
+		case key == keyCtrlR:
+			if match > 0 {
+				find(match - 1)
+			}
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = -1
+				find(from)
+			}
+		case key == keyEnter || key == '\n':
+			return true
+		case key == keyCtrlG || key == keyCtrlC:
+			e.line, e.pos = original, originalPos
+			return false
+		case key >= ' ' && key < keyUp:
+			query = append(query, key)
+			start := from
+			if match >= 0 {
+				start = match
+			}
+			match = -1
+			find(start)
+		default:
+			return false
+		}
+	}
+}
+
+// readKey читает символ или escape-последовательность клавиши
+func (e *editor) readKey() (rune, error) {
+	b, err := e.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b >= utf8.RuneSelf {
+		buf := []byte{b}
+		for !utf8.FullRune(buf) && len(buf) < utf8.UTFMax {
+			next, err := e.readByte()
+			if err != nil {
+				return 0, err
+			}
+			buf = append(buf, next)
+		}
+		r, _ := utf8.DecodeRune(buf)
+		return r, nil
+	}
+	if b != keyEscape {
+		return rune(b), nil
+	}
+
+	kind, err := e.readByte()
+	if err != nil || (kind != '[' && kind != 'O') {
+		return keyEscape, err
+	}
+	seq := []byte{}
+	for {
+		c, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	case "3~":
+		return keyDelete, nil
+	}
+	return keyEscape, nil
+}
+
+// readByte читает stdin по байту без буфера: непрочитанный ввод достанется
+// командам, которые запустит шелл
+func (e *editor) readByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := syscall.Read(e.fd, b[:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return b[0], nil
+	}
+}
+
+// complete дополняет слово перед курсором. Единственный вариант вставляется целиком,
+// из нескольких - их общее начало, а повторный Tab выводит список вариантов
+func (e *editor) complete() {
+	start, candidates := e.completions(e.line, e.pos)
+	if len(candidates) == 0 {
+		return
+	}
+	replace := func(word string) {
+		rest := append([]rune{}, e.line[e.pos:]...)
+		e.line = append(e.line[:start], []rune(word)...)
+		e.pos = len(e.line)
+		e.line = append(e.line, rest...)
+	}
+	if len(candidates) == 1 {
+		word := escapeWord(candidates[0])
+		if !strings.HasSuffix(word, "/") {
+			word += " "
+		}
+		replace(word)
+		return
+	}
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	if current := string(e.line[start:e.pos]); escapeWord(prefix) != current && len(escapeWord(prefix)) > len(current) {
+		replace(escapeWord(prefix))
+		return
+	}
+	if e.lastTab {
+		names := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			name := filepath.Base(strings.TrimSuffix(c, "/"))
+			if strings.HasSuffix(c, "/") {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(names, "  "))
+		if i := strings.LastIndexByte(e.prompt, '\n'); i >= 0 {
+			lines, _ := visiblePrompt(e.prompt[:i+1])
+			fmt.Fprint(e.out, strings.ReplaceAll(lines, "\n", "\r\n"))
+		}
+	}
+}
+
+// completions возвращает начало слова перед позицией pos и варианты его дополнения:
+// в позиции команды - встроенные команды и программы из $PATH, иначе - пути к файлам
+func (sh *Shell) completions(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 {
+		c := line[start-1]
+		if strings.ContainsRune(" \t;|&<>()", c) && (start < 2 || line[start-2] != '\\') {
+			break
+		}
+		start--
+	}
+	word := unescapePattern(string(line[start:pos]))
+
+	before := strings.TrimRight(string(line[:start]), " \t")
+	command := before == "" || strings.ContainsAny(before[len(before)-1:], ";|&(")
+	if command && !strings.Contains(word, "/") {
+		return start, sh.commandCompletions(word)
+	}
+	return start, sh.fileCompletions(word)
+}
+
+func (sh *Shell) commandCompletions(prefix string) []string {
+	seen := make(map[string]bool)
+	for name := range sh.builtinNames() {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	for name := range sh.aliases {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	path, _ := sh.lookupVar("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		dir = sh.path(dirOf(dir))
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, prefix) && !seen[name] && checkFileExecutable(filepath.Join(dir, name)) == nil {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileCompletions дополняет путь. ~ в начале раскрывается только для чтения каталога,
+// в вариантах он остается как был набран
+func (sh *Shell) fileCompletions(word string) []string {
+	dir, prefix := "", word
+	if i := strings.LastIndexByte(word, '/'); i >= 0 {
+		dir, prefix = word[:i+1], word[i+1:]
+	}
+	readDir := dir
+	if strings.HasPrefix(dir, "~") {
+		name, rest, _ := strings.Cut(dir, "/")
+		if home, ok := sh.tildeHome(name[1:]); ok {
+			readDir = home + "/" + rest
+		}
+	}
+	entries, err := os.ReadDir(sh.path(dirOf(readDir)))
+	if err != nil {
+		return nil
+	}
+	matches := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		if isDir(sh.dir, readDir+name) {
+			name += "/"
+		}
+		matches = append(matches, dir+name)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// escapeWord экранирует символы, которые шелл иначе разобрал бы по-своему
+func escapeWord(s string) string {
+	b := &strings.Builder{}
+	for _, c := range s {
+		if strings.ContainsRune(" \t\n;|&<>'\"\\$`#*?()[]{}!", c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// historySize - сколько последних строк помнит история
+const historySize = 1000
+
+// history - введенные строки. Каждая строка сразу дописывается в файл,
+// поэтому история сохраняется, даже если шелл завершится аварийно
+type history struct {
+	entries []string
+	path    string
+}
+
+// loadHistory читает историю из $HISTFILE или ~/.goshell_history
+func (sh *Shell) loadHistory() *history {
+	h := &history{}
+	if path, ok := sh.lookupVar("HISTFILE"); ok {
+		h.path = sh.path(path)
+	} else if home, ok := sh.tildeHome(""); ok {
+		h.path = filepath.Join(home, ".goshell_history")
+	}
+	if data, err := os.ReadFile(h.path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				h.entries = append(h.entries, line)
+			}
+		}
+	}
+	if len(h.entries) > historySize {
+		h.entries = h.entries[len(h.entries)-historySize:]
+	}
+	return h
+}
+
+// add запоминает строку. Пустые строки и повтор предыдущей не сохраняются
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == line) {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > historySize {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// historyCommand - builtin history: пронумерованный список введенных строк
+func (sh *Shell) historyCommand(args []string, std stdio) int {
+	for i, line := range sh.history.entries {
+		fmt.Fprintf(std.out, "%5d  %s\n", i+1, line)
+	}
+	return 0
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// accessExecute - флаг X_OK для access(2)
+const accessExecute = 0x1
+
+type commandNotFoundError struct {
+	name string
+}
+
+func (e commandNotFoundError) Error() string {
+	return e.name + ": command not found"
+}
+
+type notExecutableError struct {
+	path   string
+	reason string
+}
+
+func (e notExecutableError) Error() string {
+	return e.path + ": " + e.reason
+}
+
+// exitStatusOf возвращает код возврата для команды, которую не удалось запустить:
+// 127 - команда не найдена, 126 - найдена, но не может быть исполнена
+func exitStatusOf(err error) int {
+	var notExecutable notExecutableError
+	if errors.As(err, &notExecutable) || errors.Is(err, fs.ErrPermission) {
+		return 126
+	}
+	return 127
+}
+
+// commandHash запоминает, где в $PATH нашлась команда, чтобы не обходить каталоги
+// при каждом запуске. При изменении $PATH кеш сбрасывается. Кеш защищен мьютексом:
+// which, type и hash в конвейере работают в горутинах одновременно с запуском команд
+type commandHash struct {
+	sync.Mutex
+	path  string
+	paths map[string]string
+}
+
+// lookPath ищет исполняемый файл команды. Имена со слешем считаются путями
+// относительно текущего каталога шелла, остальные ищутся в каталогах $PATH.
+// Относительный путь в ответе тоже считается от текущего каталога шелла
+func (sh *Shell) lookPath(name string) (string, error) {
+	if name == "" {
+		return "", commandNotFoundError{name}
+	}
+	if strings.Contains(name, "/") {
+		if err := sh.checkExecutable(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+
+	sh.hash.Lock()
+	defer sh.hash.Unlock()
+	if path, ok := sh.cachedPath(name); ok {
+		return path, nil
+	}
+	pathVar, _ := sh.lookupVar("PATH")
+	for _, dir := range filepath.SplitList(pathVar) {
+		if dir == "" {
+			dir = "."
+		}
+		path := filepath.Join(dir, name)
+		if sh.checkExecutable(path) == nil {
+			sh.hash.paths[name] = path
+			return path, nil
+		}
+	}
+	return "", commandNotFoundError{name}
+}
+
+// checkExecutable - checkFileExecutable для пути относительно текущего каталога шелла.
+// В ошибке остается путь, как его передали
+func (sh *Shell) checkExecutable(path string) error {
+	err := checkFileExecutable(sh.path(path))
+	var notExecutable notExecutableError
+	var pathErr *fs.PathError
+	if errors.As(err, &notExecutable) {
+		return notExecutableError{path, notExecutable.reason}
+	} else if errors.As(err, &pathErr) {
+		pathErr.Path = path
+	}
+	return err
+}
+
+// hashedPath возвращает путь из кеша, если он еще указывает на исполняемый файл
+func (sh *Shell) hashedPath(name string) (string, bool) {
+	sh.hash.Lock()
+	defer sh.hash.Unlock()
+	return sh.cachedPath(name)
+}
+
+// cachedPath - hashedPath для вызывающего, который уже держит sh.hash
+func (sh *Shell) cachedPath(name string) (string, bool) {
+	if env, _ := sh.lookupVar("PATH"); env != sh.hash.path {
+		sh.hash.path = env
+		sh.hash.paths = make(map[string]string)
+		return "", false
+	}
+	path, ok := sh.hash.paths[name]
+	if ok && sh.checkExecutable(path) != nil {
+		delete(sh.hash.paths, name)
+		return "", false
+	}
+	return path, ok
+}
+
+// hash [-r] [name ...] - показать кеш путей, очистить его или найти и запомнить команды
+func (sh *Shell) hashCommand(args []string, std stdio) int {
+	if len(args) == 1 && args[0] == "-r" {
+		sh.hash.Lock()
+		sh.hash.paths = make(map[string]string)
+		sh.hash.Unlock()
+		return 0
+	}
+	if len(args) == 0 {
+		sh.hash.Lock()
+		defer sh.hash.Unlock()
+		sh.cachedPath("")
+		names := make([]string, 0, len(sh.hash.paths))
+		for name := range sh.hash.paths {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(std.out, "%s\t%s\n", name, sh.hash.paths[name])
+		}
+		return 0
+	}
+	status := 0
+	for _, name := range args {
+		if _, isBuiltin := sh.builtinNames()[name]; isBuiltin {
+			continue
+		}
+		if _, err := sh.lookPath(name); err != nil {
+			fmt.Fprintln(std.err, "hash:", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// typeCommand - builtin type: объясняет, как шелл выполнит каждое имя
+func (sh *Shell) typeCommand(args []string, std stdio) int {
+	status := 0
+	for _, name := range args {
+		if value, ok := sh.aliases[name]; ok {
+			fmt.Fprintf(std.out, "%s is aliased to `%s'\n", name, value)
+			continue
+		}
+		if f, ok := sh.functions[name]; ok {
+			fmt.Fprintf(std.out, "%s is a function\n%s\n", name, f)
+			continue
+		}
+		if _, isBuiltin := sh.builtinNames()[name]; isBuiltin {
+			fmt.Fprintf(std.out, "%s is a shell builtin\n", name)
+			continue
+		}
+		if path, ok := sh.hashedPath(name); ok {
+			fmt.Fprintf(std.out, "%s is hashed (%s)\n", name, path)
+			continue
+		}
+		path, err := sh.lookPath(name)
+		if err != nil {
+			fmt.Fprintf(std.err, "type: %s: not found\n", name)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(std.out, "%s is %s\n", name, path)
+	}
+	return status
+}
+
+// which печатает пути к исполняемым файлам команд
+func (sh *Shell) which(args []string, std stdio) int {
+	status := 0
+	for _, name := range args {
+		path, err := sh.lookPath(name)
+		if err != nil {
+			status = 1
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(sh.dir, path)
+		}
+		fmt.Fprintln(std.out, path)
+	}
+	return status
+}
+
+func (sh *Shell) builtinNames() map[string]struct{} {
+	names := make(map[string]struct{}, len(builtins)+len(shellBuiltins))
+	for name := range builtins {
+		names[name] = struct{}{}
+	}
+	for name := range shellBuiltins {
+		names[name] = struct{}{}
+	}
+	// timeout запускает процесс сам, поэтому его нет в таблицах
+	names["timeout"] = struct{}{}
+	if sh.options.coreutils {
+		for name := range coreutils {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
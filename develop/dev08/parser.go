@@ -0,0 +1,759 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Грамматика:
+//
+//	list     := andOr ((';' | '&' | '\n') andOr)* [';' | '&' | '\n']
+//	andOr    := pipeline (('&&' | '||') '\n'* pipeline)*
+//	pipeline := ['time' ['-p']] ['!'] command ('|' '\n'* command)*
+//	command  := simple | compound redirect* | function
+//	simple   := (word | redirect)+
+//	compound := '(' list ')' | '{' list '}'
+//	          | 'if' list 'then' list ('elif' list 'then' list)* ['else' list] 'fi'
+//	          | ('while' | 'until') list 'do' list 'done'
+//	          | 'for' name ['in' word* (';' | '\n')] '\n'* 'do' list 'done'
+//	          | 'case' word 'in' (['('] word ('|' word)* ')' list ';;')* 'esac'
+//	function := name '(' ')' '\n'* compound | 'function' name ['(' ')'] '\n'* compound
+//	redirect := [n] ('>' | '>>' | '<' | '>&' | '<&' | '&>' | '&>>' | '<<<') word
+//	          | [n] ('<<' | '<<-') word, тело читается со следующей строки
+//
+// Ключевые слова узнаются только без кавычек и только на месте имени команды
+
+// commandList - команды, выполняемые по очереди
+type commandList struct {
+	items []*andOrList
+}
+
+// andOrList - конвейеры, соединенные && и ||: ops[i] стоит между pipelines[i] и pipelines[i+1]
+type andOrList struct {
+	pipelines []*pipeline
+	ops       []tokenKind
+	// background - список завершается &, шелл не ждет его окончания
+	background bool
+}
+
+type pipeline struct {
+	commands []command
+	// negate - конвейер начинается с !, его код возврата инвертируется
+	negate bool
+	// timed - перед конвейером стоит time, posixTime - time -p
+	timed, posixTime bool
+}
+
+// command - стадия конвейера: *simpleCommand, составная команда или определение функции.
+// String восстанавливает текст, из которого parse соберет ту же команду
+type command interface {
+	String() string
+}
+
+type simpleCommand struct {
+	words     []word
+	redirects []*redirect
+}
+
+// subshellCommand - ( list ), список, выполняемый в подоболочке: cd, переменные
+// и exit внутри нее не влияют на шелл
+type subshellCommand struct {
+	body      *commandList
+	redirects []*redirect
+}
+
+// braceGroup - { list; }, список, выполняемый в текущем шелле
+type braceGroup struct {
+	body      *commandList
+	redirects []*redirect
+}
+
+// ifCommand - if conds[0]; then bodies[0]; elif conds[1]; then bodies[1]; else elseBody; fi
+type ifCommand struct {
+	conds, bodies []*commandList
+	elseBody      *commandList
+	redirects     []*redirect
+}
+
+// loopCommand - while cond; do body; done, а с until тело выполняется, пока cond не выполнится
+type loopCommand struct {
+	until      bool
+	cond, body *commandList
+	redirects  []*redirect
+}
+
+// forCommand - for name in words; do body; done. Без in перебираются позиционные параметры
+type forCommand struct {
+	name      string
+	in        bool
+	words     []word
+	body      *commandList
+	redirects []*redirect
+}
+
+// caseCommand - case subject in pattern | pattern) body;; ... esac
+type caseCommand struct {
+	subject   word
+	items     []caseItem
+	redirects []*redirect
+}
+
+type caseItem struct {
+	patterns []word
+	body     *commandList
+}
+
+// functionDef - name() body. Выполнение определения только запоминает функцию
+type functionDef struct {
+	name string
+	body command
+}
+
+// closingWords закрывают или продолжают составную команду и не могут начинать простую
+var closingWords = map[string]bool{
+	"then": true, "elif": true, "else": true, "fi": true, "do": true, "done": true, "esac": true, "}": true,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	// aliases - псевдонимы, которые раскрываются в позиции команды
+	aliases map[string]string
+}
+
+// parse строит дерево команд по строке ввода, раскрывая псевдонимы aliases
+func parse(input string, aliases map[string]string) (*commandList, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, aliases: aliases}
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != tokEOF {
+		return nil, syntaxError{p.tokens[p.pos]}
+	}
+	return list, nil
+}
+
+func (p *parser) peek() tokenKind {
+	return p.tokens[p.pos].kind
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek() == tokNewline {
+		p.advance()
+	}
+}
+
+// keyword возвращает текущее слово, если оно может быть ключевым: без кавычек и подстановок
+func (p *parser) keyword() string {
+	t := p.tokens[p.pos]
+	if t.kind != tokWord || len(t.word) != 1 || t.word[0].quote != unquoted {
+		return ""
+	}
+	return t.word[0].text
+}
+
+// unexpected - ошибка на текущем токене. Если ввод закончился, команду нужно дочитать
+func (p *parser) unexpected() error {
+	if p.peek() == tokEOF {
+		return errIncomplete
+	}
+	return syntaxError{p.tokens[p.pos]}
+}
+
+// expect пропускает обязательное ключевое слово
+func (p *parser) expect(kw string) error {
+	if p.keyword() != kw {
+		return p.unexpected()
+	}
+	p.advance()
+	return nil
+}
+
+// commandStart - может ли с текущего токена начаться команда
+func (p *parser) commandStart() bool {
+	return (p.peek() == tokWord || p.peek() == tokRedirect || p.peek() == tokLParen) && !closingWords[p.keyword()]
+}
+
+// list разбирает команды до токена, с которого команда начаться не может.
+// Что стоит после списка, проверяет вызывающий
+func (p *parser) list() (*commandList, error) {
+	list := &commandList{}
+	for {
+		p.skipNewlines()
+		if !p.commandStart() {
+			return list, nil
+		}
+		item, err := p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+
+		switch p.peek() {
+		case tokAmp:
+			item.background = true
+			p.advance()
+		case tokSemi, tokNewline:
+			p.advance()
+		default:
+			return list, nil
+		}
+	}
+}
+
+// body разбирает непустой список внутри составной команды
+func (p *parser) body() (*commandList, error) {
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.items) == 0 {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+func (p *parser) andOr() (*andOrList, error) {
+	first, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	item := &andOrList{pipelines: []*pipeline{first}}
+	for p.peek() == tokAnd || p.peek() == tokOr {
+		op := p.advance().kind
+		if err = p.continuation(); err != nil {
+			return nil, err
+		}
+		next, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		item.ops = append(item.ops, op)
+		item.pipelines = append(item.pipelines, next)
+	}
+	return item, nil
+}
+
+func (p *parser) pipeline() (*pipeline, error) {
+	pl := &pipeline{}
+	if p.keyword() == "time" {
+		start := p.pos
+		p.advance()
+		pl.timed = true
+		if p.keyword() == "-p" {
+			p.advance()
+			pl.posixTime = true
+		}
+		// time без команды - обычная команда time
+		if !p.commandStart() {
+			p.pos, pl.timed, pl.posixTime = start, false, false
+		}
+	}
+	pl.negate = p.keyword() == "!"
+	if pl.negate {
+		p.advance()
+	}
+	first, err := p.command()
+	if err != nil {
+		return nil, err
+	}
+	pl.commands = []command{first}
+	for p.peek() == tokPipe {
+		p.advance()
+		if err = p.continuation(); err != nil {
+			return nil, err
+		}
+		next, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		pl.commands = append(pl.commands, next)
+	}
+	return pl, nil
+}
+
+// continuation пропускает переводы строк после |, && и ||. Если ввод на этом
+// закончился, команду нужно дочитать
+func (p *parser) continuation() error {
+	p.skipNewlines()
+	if p.peek() == tokEOF {
+		return errIncomplete
+	}
+	return nil
+}
+
+func (p *parser) command() (command, error) {
+	p.expandAlias()
+	switch kw := p.keyword(); {
+	case kw == "function":
+		p.advance()
+		return p.function(true)
+	case isName(kw) && p.tokens[p.pos+1].kind == tokLParen:
+		return p.function(false)
+	}
+	if c, ok, err := p.compound(); ok || err != nil {
+		return c, err
+	}
+	return p.simpleCommand()
+}
+
+func (p *parser) simpleCommand() (*simpleCommand, error) {
+	cmd := &simpleCommand{}
+	for p.peek() == tokWord || p.peek() == tokRedirect {
+		t := p.advance()
+		if t.kind == tokWord {
+			cmd.words = append(cmd.words, t.word)
+			continue
+		}
+		if err := p.redirectTarget(t.redir); err != nil {
+			return nil, err
+		}
+		cmd.redirects = append(cmd.redirects, t.redir)
+	}
+	if len(cmd.words) == 0 && len(cmd.redirects) == 0 {
+		return nil, syntaxError{p.tokens[p.pos]}
+	}
+	return cmd, nil
+}
+
+// redirectTarget дочитывает файл или строку перенаправления. У heredoc цель уже
+// прочитана лексером вместе с телом
+func (p *parser) redirectTarget(r *redirect) error {
+	if r.op != "<<" && r.op != "<<-" {
+		if p.peek() != tokWord {
+			return syntaxError{p.tokens[p.pos]}
+		}
+		r.target = p.advance().word
+	}
+	return nil
+}
+
+// redirects разбирает перенаправления после составной команды
+func (p *parser) redirects() ([]*redirect, error) {
+	var list []*redirect
+	for p.peek() == tokRedirect {
+		t := p.advance()
+		if err := p.redirectTarget(t.redir); err != nil {
+			return nil, err
+		}
+		list = append(list, t.redir)
+	}
+	return list, nil
+}
+
+// compound разбирает составную команду, если с текущего слова она начинается
+func (p *parser) compound() (command, bool, error) {
+	var c command
+	var err error
+	if p.peek() == tokLParen {
+		c, err = p.subshellCommand()
+		return c, true, err
+	}
+	switch p.keyword() {
+	case "{":
+		c, err = p.braceGroup()
+	case "if":
+		c, err = p.ifCommand()
+	case "while", "until":
+		c, err = p.loop()
+	case "for":
+		c, err = p.forCommand()
+	case "case":
+		c, err = p.caseCommand()
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	return c, true, nil
+}
+
+// function разбирает определение функции. После function скобки необязательны
+func (p *parser) function(keyword bool) (*functionDef, error) {
+	name := p.keyword()
+	if !isName(name) {
+		return nil, p.unexpected()
+	}
+	p.advance()
+	if p.peek() == tokLParen || !keyword {
+		p.advance()
+		if p.peek() != tokRParen {
+			return nil, p.unexpected()
+		}
+		p.advance()
+	}
+	p.skipNewlines()
+	body, ok, err := p.compound()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, p.unexpected()
+	}
+	return &functionDef{name: name, body: body}, nil
+}
+
+func (p *parser) subshellCommand() (*subshellCommand, error) {
+	p.advance()
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != tokRParen {
+		return nil, p.unexpected()
+	}
+	p.advance()
+	c := &subshellCommand{body: body}
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+func (p *parser) braceGroup() (*braceGroup, error) {
+	p.advance()
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("}"); err != nil {
+		return nil, err
+	}
+	c := &braceGroup{body: body}
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+func (p *parser) ifCommand() (*ifCommand, error) {
+	p.advance()
+	c := &ifCommand{}
+	for {
+		cond, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		c.conds = append(c.conds, cond)
+		c.bodies = append(c.bodies, body)
+		if p.keyword() != "elif" {
+			break
+		}
+		p.advance()
+	}
+	if p.keyword() == "else" {
+		p.advance()
+		body, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		c.elseBody = body
+	}
+	if err := p.expect("fi"); err != nil {
+		return nil, err
+	}
+	var err error
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+func (p *parser) loop() (*loopCommand, error) {
+	c := &loopCommand{until: p.advance().word.String() == "until"}
+	var err error
+	if c.cond, err = p.body(); err != nil {
+		return nil, err
+	}
+	if c.body, err = p.doGroup(); err != nil {
+		return nil, err
+	}
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+// doGroup разбирает тело цикла do list done
+func (p *parser) doGroup() (*commandList, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("done"); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (p *parser) forCommand() (*forCommand, error) {
+	p.advance()
+	name := p.keyword()
+	if !isName(name) {
+		return nil, p.unexpected()
+	}
+	p.advance()
+	c := &forCommand{name: name}
+	p.skipNewlines()
+	if p.keyword() == "in" {
+		p.advance()
+		c.in = true
+		for p.peek() == tokWord {
+			c.words = append(c.words, p.advance().word)
+		}
+		if p.peek() != tokSemi && p.peek() != tokNewline {
+			return nil, p.unexpected()
+		}
+		p.advance()
+	} else if p.peek() == tokSemi {
+		p.advance()
+	}
+	p.skipNewlines()
+	var err error
+	if c.body, err = p.doGroup(); err != nil {
+		return nil, err
+	}
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+func (p *parser) caseCommand() (*caseCommand, error) {
+	p.advance()
+	if p.peek() != tokWord {
+		return nil, p.unexpected()
+	}
+	c := &caseCommand{subject: p.advance().word}
+	p.skipNewlines()
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	for {
+		p.skipNewlines()
+		if p.keyword() == "esac" {
+			p.advance()
+			break
+		}
+		if p.peek() == tokLParen {
+			p.advance()
+		}
+		item := caseItem{}
+		for {
+			if p.peek() != tokWord {
+				return nil, p.unexpected()
+			}
+			item.patterns = append(item.patterns, p.advance().word)
+			if p.peek() != tokPipe {
+				break
+			}
+			p.advance()
+		}
+		if p.peek() != tokRParen {
+			return nil, p.unexpected()
+		}
+		p.advance()
+		body, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		item.body = body
+		c.items = append(c.items, item)
+		if p.peek() == tokDSemi {
+			p.advance()
+		} else if p.keyword() != "esac" {
+			return nil, p.unexpected()
+		}
+	}
+	var err error
+	c.redirects, err = p.redirects()
+	return c, err
+}
+
+// String восстанавливает текст списка, каждая команда в нем завершается ; или &
+func (l *commandList) String() string {
+	items := make([]string, 0, len(l.items))
+	for _, item := range l.items {
+		if item.background {
+			items = append(items, item.String()+" &")
+		} else {
+			items = append(items, item.String()+";")
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+// String восстанавливает текст списка для вывода в jobs
+func (item *andOrList) String() string {
+	b := &strings.Builder{}
+	for i, pl := range item.pipelines {
+		if i > 0 {
+			fmt.Fprintf(b, " %s ", item.ops[i-1])
+		}
+		b.WriteString(pl.String())
+	}
+	return b.String()
+}
+
+func (pl *pipeline) String() string {
+	commands := make([]string, 0, len(pl.commands))
+	for _, cmd := range pl.commands {
+		commands = append(commands, cmd.String())
+	}
+	s := strings.Join(commands, " | ")
+	if pl.negate {
+		s = "! " + s
+	}
+	if pl.posixTime {
+		return "time -p " + s
+	}
+	if pl.timed {
+		return "time " + s
+	}
+	return s
+}
+
+func (c *simpleCommand) String() string {
+	fields := make([]string, 0, len(c.words)+len(c.redirects))
+	for _, w := range c.words {
+		fields = append(fields, w.source())
+	}
+	for _, r := range c.redirects {
+		fields = append(fields, r.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+func (c *subshellCommand) String() string {
+	return withRedirects("( "+c.body.String()+" )", c.redirects)
+}
+
+func (c *braceGroup) String() string {
+	return withRedirects("{ "+c.body.String()+" }", c.redirects)
+}
+
+func (c *ifCommand) String() string {
+	b := &strings.Builder{}
+	for i, cond := range c.conds {
+		if i > 0 {
+			b.WriteString(" el")
+		}
+		fmt.Fprintf(b, "if %s then %s", cond, c.bodies[i])
+	}
+	if c.elseBody != nil {
+		fmt.Fprintf(b, " else %s", c.elseBody)
+	}
+	b.WriteString(" fi")
+	return withRedirects(b.String(), c.redirects)
+}
+
+func (c *loopCommand) String() string {
+	kw := "while"
+	if c.until {
+		kw = "until"
+	}
+	return withRedirects(fmt.Sprintf("%s %s do %s done", kw, c.cond, c.body), c.redirects)
+}
+
+func (c *forCommand) String() string {
+	b := &strings.Builder{}
+	b.WriteString("for " + c.name)
+	if c.in {
+		b.WriteString(" in")
+		for _, w := range c.words {
+			b.WriteString(" " + w.source())
+		}
+	}
+	fmt.Fprintf(b, "; do %s done", c.body)
+	return withRedirects(b.String(), c.redirects)
+}
+
+func (c *caseCommand) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "case %s in", c.subject.source())
+	for _, item := range c.items {
+		patterns := make([]string, 0, len(item.patterns))
+		for _, w := range item.patterns {
+			patterns = append(patterns, w.source())
+		}
+		fmt.Fprintf(b, " %s) %s ;;", strings.Join(patterns, " | "), item.body)
+	}
+	b.WriteString(" esac")
+	return withRedirects(b.String(), c.redirects)
+}
+
+func (f *functionDef) String() string {
+	return f.name + "() " + f.body.String()
+}
+
+func withRedirects(s string, redirects []*redirect) string {
+	for _, r := range redirects {
+		s += " " + r.String()
+	}
+	return s
+}
+
+// String восстанавливает перенаправление. Тело heredoc записывается строкой для <<<,
+// чтобы команда помещалась в одну строку
+func (r *redirect) String() string {
+	fd := ""
+	if (r.op[0] == '<' && r.fd != 0) || (r.op[0] == '>' && r.fd != 1) {
+		fd = strconv.Itoa(r.fd)
+	}
+	if r.op == "<<" || r.op == "<<-" {
+		body := strings.TrimSuffix(r.heredoc, "\n")
+		if r.expand {
+			return fd + "<<<" + heredocQuote(body)
+		}
+		return fd + "<<<" + quote(body)
+	}
+	return fd + r.op + r.target.source()
+}
+
+// heredocQuote записывает тело heredoc с подстановками в двойных кавычках. В heredoc
+// обратный слеш перед " остается в тексте, в кавычках его нужно экранировать
+func heredocQuote(s string) string {
+	b := &strings.Builder{}
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 == len(s):
+			b.WriteString(`\\`)
+		case s[i] == '\\' && s[i+1] == '"':
+			b.WriteString(`\\\"`)
+			i++
+		case s[i] == '\\':
+			b.WriteString(s[i : i+2])
+			i++
+		case s[i] == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quote заключает s в одинарные кавычки, если без них оно разобралось бы иначе
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n;|&<>'\"\\$`#*?~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
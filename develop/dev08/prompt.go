@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// начало и конец непечатаемой части приглашения, как \[ и \] в bash.
+// Редактор строки не считает ее ширину
+const (
+	promptIgnoreStart = '\x01'
+	promptIgnoreEnd   = '\x02'
+)
+
+// prompt возвращает приглашение из $PS1. Без $PS1 это текущий каталог и "> $ "
+func (sh *Shell) prompt(now time.Time) string {
+	ps1, ok := sh.lookupVar("PS1")
+	if !ok {
+		return sh.logicalDir() + "> $ "
+	}
+	return sh.expandPrompt(ps1, now)
+}
+
+// expandPrompt раскрывает в приглашении escape-последовательности:
+//
+//	\u - пользователь, \h - имя хоста до точки, \H - полное имя хоста,
+//	\w - текущий каталог с ~ вместо домашнего, \W - последний компонент каталога,
+//	\? - код возврата последней команды, \$ - # у root, иначе $,
+//	\t - время ЧЧ:ММ:СС, \T - 12-часовое время, \A - ЧЧ:ММ, \@ - 12-часовое с am/pm,
+//	\d - дата вида "Mon Jan 02", \n - перевод строки, \e - ESC, \\ - обратный слеш,
+//	\[ и \] - начало и конец непечатаемой части, например цвета
+func (sh *Shell) expandPrompt(ps1 string, now time.Time) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(ps1); i++ {
+		if ps1[i] != '\\' || i+1 == len(ps1) {
+			b.WriteByte(ps1[i])
+			continue
+		}
+		i++
+		switch ps1[i] {
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				b.WriteString(u.Username)
+			}
+		case 'h', 'H':
+			host, _ := os.Hostname()
+			if ps1[i] == 'h' {
+				host, _, _ = strings.Cut(host, ".")
+			}
+			b.WriteString(host)
+		case 'w':
+			b.WriteString(sh.shortenHome(sh.logicalDir()))
+		case 'W':
+			dir := sh.shortenHome(sh.logicalDir())
+			if dir != "~" && dir != "/" {
+				dir = filepath.Base(dir)
+			}
+			b.WriteString(dir)
+		case '?':
+			b.WriteString(strconv.Itoa(sh.lastStatus))
+		case '$':
+			if os.Geteuid() == 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('$')
+			}
+		case 't':
+			b.WriteString(now.Format("15:04:05"))
+		case 'T':
+			b.WriteString(now.Format("03:04:05"))
+		case 'A':
+			b.WriteString(now.Format("15:04"))
+		case '@':
+			b.WriteString(now.Format("03:04 PM"))
+		case 'd':
+			b.WriteString(now.Format("Mon Jan 02"))
+		case 'n':
+			b.WriteByte('\n')
+		case 'e':
+			b.WriteByte('\x1b')
+		case '\\':
+			b.WriteByte('\\')
+		case '[':
+			b.WriteByte(promptIgnoreStart)
+		case ']':
+			b.WriteByte(promptIgnoreEnd)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(ps1[i])
+		}
+	}
+	return b.String()
+}
+
+// shortenHome заменяет домашний каталог в начале пути на ~
+func (sh *Shell) shortenHome(dir string) string {
+	home, ok := sh.lookupVar("HOME")
+	home = strings.TrimSuffix(home, "/")
+	if !ok || home == "" {
+		return dir
+	}
+	if dir == home {
+		return "~"
+	}
+	if strings.HasPrefix(dir, home+"/") {
+		return "~" + dir[len(home):]
+	}
+	return dir
+}
+
+// visiblePrompt убирает из приглашения метки непечатаемой части и возвращает
+// его вместе с шириной на экране
+func visiblePrompt(prompt string) (string, int) {
+	b := &strings.Builder{}
+	width, hidden := 0, false
+	for _, r := range prompt {
+		switch {
+		case r == promptIgnoreStart:
+			hidden = true
+		case r == promptIgnoreEnd:
+			hidden = false
+		default:
+			b.WriteRune(r)
+			if !hidden {
+				width++
+			}
+		}
+	}
+	return b.String(), width
+}
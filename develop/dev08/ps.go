@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// clockTicks - USER_HZ, в этих единицах в /proc/[pid]/stat считается время процессора
+const clockTicks = 100
+
+// procInfo - сведения о процессе из /proc/[pid]/stat, status и cmdline
+type procInfo struct {
+	pid, ppid, pgrp, session int
+	ttyNr, tpgid             int
+	nice, threads            int
+	state                    byte
+	comm                     string
+	args                     []string
+	uid                      int
+	user                     string
+	// utime, stime и start - в тиках clockTicks, rss и vsz - в килобайтах
+	utime, stime, start uint64
+	rss, vsz            int64
+	cpu                 float64
+}
+
+// parseStat разбирает строку /proc/[pid]/stat. Имя команды стоит в скобках
+// и само может содержать пробелы и скобки, поэтому ищется последняя )
+func parseStat(line string) (*procInfo, error) {
+	open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("malformed stat: %q", line)
+	}
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 || len(fields[0]) != 1 {
+		return nil, fmt.Errorf("malformed stat: %q", line)
+	}
+	p := &procInfo{comm: line[open+1 : end], state: fields[0][0]}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return nil, fmt.Errorf("malformed stat: %q", line)
+	}
+	p.pid = pid
+	ints := []*int{&p.ppid, &p.pgrp, &p.session, &p.ttyNr, &p.tpgid}
+	for i, v := range ints {
+		if *v, err = strconv.Atoi(fields[i+1]); err != nil {
+			return nil, fmt.Errorf("malformed stat: %q", line)
+		}
+	}
+	p.nice, _ = strconv.Atoi(fields[16])
+	p.threads, _ = strconv.Atoi(fields[17])
+	p.utime, _ = strconv.ParseUint(fields[11], 10, 64)
+	p.stime, _ = strconv.ParseUint(fields[12], 10, 64)
+	p.start, _ = strconv.ParseUint(fields[19], 10, 64)
+	vsz, _ := strconv.ParseInt(fields[20], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	p.vsz, p.rss = vsz/1024, rss*int64(os.Getpagesize())/1024
+	return p, nil
+}
+
+// readProc читает сведения о процессе pid. Процесс мог завершиться, пока шел список
+func readProc(pid int, uptime float64, users map[int]string) (*procInfo, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseStat(strings.TrimSpace(string(stat)))
+	if err != nil {
+		return nil, err
+	}
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if ids, ok := strings.CutPrefix(line, "Uid:"); ok {
+				// ps показывает действующий uid, второе поле
+				if f := strings.Fields(ids); len(f) > 1 {
+					p.uid, _ = strconv.Atoi(f[1])
+				}
+				break
+			}
+		}
+	}
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+		p.args = strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+	}
+
+	name, ok := users[p.uid]
+	if !ok {
+		name = strconv.Itoa(p.uid)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		users[p.uid] = name
+	}
+	p.user = name
+	if elapsed := uptime - float64(p.start)/clockTicks; elapsed > 0 {
+		p.cpu = float64(p.utime+p.stime) / clockTicks / elapsed * 100
+	}
+	return p, nil
+}
+
+// listProcs читает все процессы из /proc
+func listProcs() ([]*procInfo, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	uptime := 0.0
+	if b, err := os.ReadFile("/proc/uptime"); err == nil {
+		if f := strings.Fields(string(b)); len(f) > 0 {
+			uptime, _ = strconv.ParseFloat(f[0], 64)
+		}
+	}
+	users := map[int]string{}
+	procs := make([]*procInfo, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if p, err := readProc(pid, uptime, users); err == nil {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
+
+// stat возвращает STAT, как в procps: состояние и флаги < (высокий приоритет),
+// N (низкий), s (лидер сессии), l (несколько потоков), + (группа переднего плана)
+func (p *procInfo) stat() string {
+	s := string(p.state)
+	switch {
+	case p.nice < 0:
+		s += "<"
+	case p.nice > 0:
+		s += "N"
+	}
+	if p.session == p.pid {
+		s += "s"
+	}
+	if p.threads > 1 {
+		s += "l"
+	}
+	if p.tpgid > 0 && p.pgrp == p.tpgid {
+		s += "+"
+	}
+	return s
+}
+
+// tty возвращает имя терминала по номеру устройства tty_nr
+func (p *procInfo) tty() string {
+	major := (p.ttyNr >> 8) & 0xfff
+	minor := (p.ttyNr & 0xff) | ((p.ttyNr >> 12) & 0xfff00)
+	switch {
+	case p.ttyNr == 0:
+		return "?"
+	case major >= 136 && major <= 143:
+		return "pts/" + strconv.Itoa((major-136)*256+minor)
+	case major == 4 && minor < 64:
+		return "tty" + strconv.Itoa(minor)
+	case major == 4:
+		return "ttyS" + strconv.Itoa(minor-64)
+	}
+	return "?"
+}
+
+// command - командная строка процесса. У потоков ядра ее нет, они показываются как [comm]
+func (p *procInfo) command() string {
+	if len(p.args) == 0 {
+		return "[" + p.comm + "]"
+	}
+	return strings.Join(p.args, " ")
+}
+
+// formatTime выводит время в тиках как [DD-]HH:MM:SS
+func formatTime(ticks uint64) string {
+	s := ticks / clockTicks
+	if days := s / 86400; days > 0 {
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, s/3600%24, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// psColumn - колонка вывода ps. Числовые колонки выравниваются вправо
+// и сортируются как числа
+type psColumn struct {
+	header string
+	number bool
+	value  func(p *procInfo) string
+	key    func(p *procInfo) float64
+}
+
+func intColumn(header string, key func(p *procInfo) float64) psColumn {
+	return psColumn{header, true, func(p *procInfo) string { return strconv.FormatFloat(key(p), 'f', 0, 64) }, key}
+}
+
+func textColumn(header string, value func(p *procInfo) string) psColumn {
+	return psColumn{header: header, value: value}
+}
+
+var psColumns = map[string]psColumn{
+	"pid":  intColumn("PID", func(p *procInfo) float64 { return float64(p.pid) }),
+	"ppid": intColumn("PPID", func(p *procInfo) float64 { return float64(p.ppid) }),
+	"pgid": intColumn("PGID", func(p *procInfo) float64 { return float64(p.pgrp) }),
+	"sid":  intColumn("SID", func(p *procInfo) float64 { return float64(p.session) }),
+	"uid":  intColumn("UID", func(p *procInfo) float64 { return float64(p.uid) }),
+	"ni":   intColumn("NI", func(p *procInfo) float64 { return float64(p.nice) }),
+	"nlwp": intColumn("NLWP", func(p *procInfo) float64 { return float64(p.threads) }),
+	"rss":  intColumn("RSS", func(p *procInfo) float64 { return float64(p.rss) }),
+	"vsz":  intColumn("VSZ", func(p *procInfo) float64 { return float64(p.vsz) }),
+	"%cpu": {"%CPU", true, func(p *procInfo) string { return strconv.FormatFloat(p.cpu, 'f', 1, 64) },
+		func(p *procInfo) float64 { return p.cpu }},
+	"time": {"TIME", true, func(p *procInfo) string { return formatTime(p.utime + p.stime) },
+		func(p *procInfo) float64 { return float64(p.utime + p.stime) }},
+	"user": textColumn("USER", func(p *procInfo) string { return p.user }),
+	"stat": textColumn("STAT", (*procInfo).stat),
+	"tty":  textColumn("TT", (*procInfo).tty),
+	"comm": textColumn("COMMAND", func(p *procInfo) string { return p.comm }),
+	"args": textColumn("CMD", (*procInfo).command),
+}
+
+// psAliases - другие имена колонок, принятые в procps
+var psAliases = map[string]string{
+	"pcpu": "%cpu", "s": "stat", "state": "stat", "tname": "tty", "tt": "tty", "ucmd": "comm",
+	"cmd": "args", "command": "args", "cputime": "time", "rssize": "rss", "vsize": "vsz", "nice": "ni",
+	"euid": "uid", "euser": "user", "pgrp": "pgid", "session": "sid", "thcount": "nlwp",
+}
+
+func psColumnByName(name string) (psColumn, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := psAliases[name]; ok {
+		name = alias
+	}
+	c, ok := psColumns[name]
+	return c, ok
+}
+
+// ps [-e|-A] [-f] [-o col,...[=HEADER]] [--sort [+|-]col,...] - процессы из /proc.
+// Без -e выводятся процессы пользователя шелла на том же терминале
+func ps(args []string, stdout, stderr io.Writer) int {
+	all, full := false, false
+	var format, sortKeys []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--sort" || arg == "-o":
+			if i+1 >= len(args) {
+				fmt.Fprintf(stderr, "ps: %s: option requires an argument\n", arg)
+				return 1
+			}
+			i++
+			if arg == "-o" {
+				format = append(format, args[i])
+			} else {
+				sortKeys = append(sortKeys, strings.Split(args[i], ",")...)
+			}
+		case strings.HasPrefix(arg, "--sort="):
+			sortKeys = append(sortKeys, strings.Split(strings.TrimPrefix(arg, "--sort="), ",")...)
+		case strings.HasPrefix(arg, "-o"):
+			format = append(format, arg[2:])
+		case len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			for _, c := range arg[1:] {
+				switch c {
+				case 'e', 'A':
+					all = true
+				case 'f':
+					full = true
+				default:
+					fmt.Fprintf(stderr, "ps: unknown option -%c\n", c)
+					return 1
+				}
+			}
+		default:
+			fmt.Fprintf(stderr, "ps: unknown option %s\n", arg)
+			return 1
+		}
+	}
+
+	columns, err := psFormat(format, full)
+	if err != nil {
+		fmt.Fprintln(stderr, "ps:", err)
+		return 1
+	}
+	less, err := psSort(sortKeys)
+	if err != nil {
+		fmt.Fprintln(stderr, "ps:", err)
+		return 1
+	}
+	procs, err := listProcs()
+	if err != nil {
+		fmt.Fprintln(stderr, "ps:", err)
+		return 1
+	}
+	if !all {
+		self := &procInfo{uid: os.Geteuid(), session: os.Getpid()}
+		if i := slices.IndexFunc(procs, func(p *procInfo) bool { return p.pid == os.Getpid() }); i >= 0 {
+			self = procs[i]
+		}
+		procs = slices.DeleteFunc(procs, func(p *procInfo) bool {
+			// без терминала процессы отбираются по сессии шелла
+			if self.ttyNr == 0 {
+				return p.uid != self.uid || p.session != self.session
+			}
+			return p.uid != self.uid || p.ttyNr != self.ttyNr
+		})
+	}
+	slices.SortStableFunc(procs, less)
+	writePs(stdout, columns, procs)
+	return 0
+}
+
+// psFormat собирает колонки из -o. По умолчанию - PID PPID USER STAT %CPU RSS TIME CMD,
+// CMD с -f - вся командная строка, без -f - имя команды
+func psFormat(format []string, full bool) ([]psColumn, error) {
+	if len(format) == 0 {
+		cmd := "comm"
+		if full {
+			cmd = "args"
+		}
+		format = []string{"pid,ppid,user,stat,%cpu,rss,time," + cmd + "=CMD"}
+	}
+	var columns []psColumn
+	for _, spec := range format {
+		// после = до конца аргумента идет заголовок последней колонки, как в procps
+		names, header, hasHeader := strings.Cut(spec, "=")
+		list := strings.Split(names, ",")
+		for i, name := range list {
+			c, ok := psColumnByName(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown column %q", name)
+			}
+			if hasHeader && i == len(list)-1 {
+				c.header = header
+			}
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
+
+// psSort строит порядок для --sort: + или без знака - по возрастанию, - - по убыванию.
+// Без ключей процессы идут по PID
+func psSort(keys []string) (func(a, b *procInfo) int, error) {
+	if len(keys) == 0 {
+		keys = []string{"pid"}
+	}
+	compares := make([]func(a, b *procInfo) int, 0, len(keys))
+	for _, key := range keys {
+		desc := strings.HasPrefix(key, "-")
+		c, ok := psColumnByName(strings.TrimLeft(key, "+-"))
+		if !ok {
+			return nil, fmt.Errorf("unknown sort key %q", key)
+		}
+		compare := func(a, b *procInfo) int { return strings.Compare(c.value(a), c.value(b)) }
+		if c.number {
+			compare = func(a, b *procInfo) int { return cmp.Compare(c.key(a), c.key(b)) }
+		}
+		if desc {
+			asc := compare
+			compare = func(a, b *procInfo) int { return asc(b, a) }
+		}
+		compares = append(compares, compare)
+	}
+	return func(a, b *procInfo) int {
+		for _, compare := range compares {
+			if r := compare(a, b); r != 0 {
+				return r
+			}
+		}
+		return 0
+	}, nil
+}
+
+// writePs выводит таблицу: ширина колонки - по самому длинному значению,
+// последняя колонка не дополняется пробелами. Если все заголовки пустые,
+// как в ps -o pid= -o comm=, строки заголовков нет
+func writePs(w io.Writer, columns []psColumn, procs []*procInfo) {
+	rows := make([][]string, 0, len(procs)+1)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.header
+	}
+	if strings.Join(header, "") != "" {
+		rows = append(rows, header)
+	}
+	for _, p := range procs {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = c.value(p)
+		}
+		rows = append(rows, row)
+	}
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range rows {
+		b := &strings.Builder{}
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			switch {
+			case columns[i].number:
+				fmt.Fprintf(b, "%*s", widths[i], cell)
+			case i == len(row)-1:
+				b.WriteString(cell)
+			default:
+				fmt.Fprintf(b, "%-*s", widths[i], cell)
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fdTable - дескрипторы, с которыми запускается команда: files[n] станет ее дескриптором n,
+// nil - закрытый дескриптор. owned - каналы и файлы, открытые для этой команды,
+// шелл закрывает их, когда они больше не нужны
+type fdTable struct {
+	files []*os.File
+	owned []*os.File
+}
+
+func newFdTable(stdin, stdout, stderr *os.File) *fdTable {
+	return &fdTable{files: []*os.File{stdin, stdout, stderr}}
+}
+
+func (t *fdTable) get(fd int) *os.File {
+	if fd < len(t.files) {
+		return t.files[fd]
+	}
+	return nil
+}
+
+func (t *fdTable) set(fd int, f *os.File) {
+	for fd >= len(t.files) {
+		t.files = append(t.files, nil)
+	}
+	t.files[fd] = f
+}
+
+// close закрывает открытые для команды файлы, каждый по одному разу
+func (t *fdTable) close() {
+	closed := make(map[*os.File]bool, len(t.owned))
+	for _, f := range t.owned {
+		if !closed[f] {
+			f.Close()
+			closed[f] = true
+		}
+	}
+	t.owned = nil
+}
+
+// stdio отдает первые три дескриптора встроенной команде. Закрытый ввод читается
+// как пустой, вывод в закрытый дескриптор отбрасывается
+func (t *fdTable) stdio() stdio {
+	std := stdio{in: strings.NewReader(""), out: io.Discard, err: io.Discard}
+	if f := t.get(0); f != nil {
+		std.in = f
+	}
+	if f := t.get(1); f != nil {
+		std.out = f
+	}
+	if f := t.get(2); f != nil {
+		std.err = f
+	}
+	return std
+}
+
+// own отдает таблице открытый для команды файл
+func (t *fdTable) own(f *os.File, err error) (*os.File, error) {
+	if err != nil {
+		return nil, err
+	}
+	t.owned = append(t.owned, f)
+	return f, nil
+}
+
+// open открывает файл name относительно текущего каталога шелла. В ошибке
+// остается имя, как его написали в команде
+func (sh *Shell) open(name string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(sh.path(name), flag, 0666)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = name
+	}
+	return f, err
+}
+
+// redirect применяет перенаправления по порядку слева направо, так что
+// `> out 2>&1` отправляет оба потока в файл, а `2>&1 > out` - только stdout
+func (sh *Shell) redirect(t *fdTable, redirects []*redirect) error {
+	const (
+		truncate = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		appendTo = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	)
+	for _, r := range redirects {
+		target, err := sh.expandString(r.target)
+		if err != nil {
+			return err
+		}
+		switch r.op {
+		case ">", ">>", "<":
+			flag := truncate
+			if r.op == ">>" {
+				flag = appendTo
+			} else if r.op == "<" {
+				flag = os.O_RDONLY
+			}
+			f, err := t.own(sh.open(target, flag))
+			if err != nil {
+				return err
+			}
+			t.set(r.fd, f)
+		case "&>", "&>>":
+			flag := truncate
+			if r.op == "&>>" {
+				flag = appendTo
+			}
+			f, err := t.own(sh.open(target, flag))
+			if err != nil {
+				return err
+			}
+			t.set(1, f)
+			t.set(2, f)
+		case ">&", "<&":
+			if target == "-" {
+				t.set(r.fd, nil)
+				continue
+			}
+			n, err := strconv.Atoi(target)
+			if err != nil {
+				// >&file - то же, что &>file
+				if r.op == ">&" && r.fd == 1 {
+					f, err := t.own(sh.open(target, truncate))
+					if err != nil {
+						return err
+					}
+					t.set(1, f)
+					t.set(2, f)
+					continue
+				}
+				return fmt.Errorf("%s: ambiguous redirect", target)
+			}
+			f := t.get(n)
+			if f == nil {
+				return fmt.Errorf("%d: bad file descriptor", n)
+			}
+			t.set(r.fd, f)
+		case "<<", "<<-", "<<<":
+			text := r.heredoc
+			if r.op == "<<<" {
+				text = target + "\n"
+			} else if r.expand {
+				if text, err = sh.expandHeredoc(text); err != nil {
+					return err
+				}
+			}
+			f, err := hereDocument(text)
+			if err != nil {
+				return err
+			}
+			t.owned = append(t.owned, f)
+			t.set(r.fd, f)
+		}
+	}
+	return nil
+}
+
+// hereDocument отдает text через канал: команда читает его как обычный stdin
+func hereDocument(text string) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		io.WriteString(w, text)
+		w.Close()
+	}()
+	return r, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shellOptions - флаги, которые меняет set: errexit (-e) завершает шелл после первой
+// неудачной команды, xtrace (-x) печатает команды перед выполнением,
+// coreutils (только -o coreutils, включен по умолчанию) выполняет cat, sort, grep,
+// cut и wget в самом шелле
+type shellOptions struct {
+	errexit   bool
+	xtrace    bool
+	coreutils bool
+}
+
+// exitShell - паника, которой exit и set -e завершают шелл. Ее перехватывает
+// ближайшая подоболочка или main, поэтому exit внутри $(...) завершает только подстановку
+type exitShell int
+
+// runSource выполняет команды из r до конца ввода и возвращает код возврата последней
+func (sh *Shell) runSource(r io.Reader) int {
+	reader := plainReader{in: bufio.NewReader(r), quiet: true}
+	for {
+		list, err := sh.readCommand(reader, "")
+		if err == io.EOF {
+			return sh.lastStatus
+		}
+		if err != nil {
+			fmt.Fprintln(sh.stderr, err)
+			sh.lastStatus = 2
+			return sh.lastStatus
+		}
+		sh.runList(list)
+		if sh.flow.kind != flowNone {
+			return sh.lastStatus
+		}
+	}
+}
+
+// runScript выполняет файл скрипта с аргументами args, как sh script args
+func (sh *Shell) runScript(path string, args []string) int {
+	f, err := sh.open(path, os.O_RDONLY)
+	if err != nil {
+		fmt.Fprintln(sh.stderr, err)
+		return 127
+	}
+	defer f.Close()
+	sh.name, sh.positional = path, args
+	return sh.runSource(f)
+}
+
+// loadRC выполняет ~/.goshellrc при запуске интерактивного шелла
+func (sh *Shell) loadRC() {
+	home, ok := sh.tildeHome("")
+	if !ok {
+		return
+	}
+	f, err := sh.open(filepath.Join(home, ".goshellrc"), os.O_RDONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sh.runSource(f)
+}
+
+// trace печатает команду для set -x
+func (sh *Shell) trace(assigns []assignment, args []string) {
+	fields := make([]string, 0, len(assigns)+len(args))
+	for _, a := range assigns {
+		fields = append(fields, a.name+"="+quote(a.value))
+	}
+	for _, arg := range args {
+		fields = append(fields, quote(arg))
+	}
+	fmt.Fprintln(sh.stderr, "+", strings.Join(fields, " "))
+}
+
+// setOption включает или выключает флаг по букве или по имени для set -o
+func (sh *Shell) setOption(name string, on bool) bool {
+	switch name {
+	case "e", "errexit":
+		sh.options.errexit = on
+	case "x", "xtrace":
+		sh.options.xtrace = on
+	case "coreutils":
+		sh.options.coreutils = on
+	default:
+		return false
+	}
+	return true
+}
+
+// setCommand - set [-ex] [+ex] [-o name] [--] [args...]. Аргументы после флагов
+// становятся позиционными параметрами, без аргументов печатаются все переменные
+func (sh *Shell) setCommand(args []string, std stdio) int {
+	if len(args) == 0 {
+		lines := make([]string, 0, len(sh.vars))
+		for name, value := range sh.vars {
+			lines = append(lines, name+"="+quote(value))
+		}
+		for _, kv := range sh.environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			lines = append(lines, name+"="+quote(value))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Fprintln(std.out, line)
+		}
+		return 0
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			sh.positional = append([]string{}, args[i+1:]...)
+			return 0
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			sh.positional = append([]string{}, args[i:]...)
+			return 0
+		}
+		on := arg[0] == '-'
+		if arg[1:] == "o" {
+			if i+1 >= len(args) || !sh.setOption(args[i+1], on) {
+				fmt.Fprintln(std.err, "set: -o: invalid option name")
+				return 2
+			}
+			i++
+			continue
+		}
+		for _, c := range arg[1:] {
+			if !sh.setOption(string(c), on) {
+				fmt.Fprintf(std.err, "set: %c%c: invalid option\n", arg[0], c)
+				return 2
+			}
+		}
+	}
+	return 0
+}
+
+// shift [n] сдвигает позиционные параметры влево
+func (sh *Shell) shift(args []string, std stdio) int {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			fmt.Fprintf(std.err, "shift: %s: numeric argument required\n", args[0])
+			return 1
+		}
+	}
+	if n > len(sh.positional) {
+		return 1
+	}
+	sh.positional = sh.positional[n:]
+	return 0
+}
+
+// source file [args] выполняет команды из файла в текущем шелле
+func (sh *Shell) source(args []string, std stdio) int {
+	if len(args) == 0 {
+		fmt.Fprintln(std.err, "source: filename argument required")
+		return 2
+	}
+	f, err := sh.open(args[0], os.O_RDONLY)
+	if err != nil {
+		fmt.Fprintln(std.err, "source:", err)
+		return 1
+	}
+	defer f.Close()
+	if len(args) > 1 {
+		saved := sh.positional
+		sh.positional = args[1:]
+		defer func() { sh.positional = saved }()
+	}
+	sh.calls++
+	defer func() { sh.calls-- }()
+	status := sh.runSource(f)
+	if sh.flow.kind == flowReturn {
+		sh.flow.kind = flowNone
+	}
+	return status
+}
+
+// exitCommand - exit [n]: без аргумента шелл завершается с кодом последней команды
+func (sh *Shell) exitCommand(args []string, std stdio) int {
+	status := sh.lastStatus
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(std.err, "exit: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	panic(exitShell(status))
+}
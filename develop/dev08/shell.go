@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Shell - шелл с заданными потоками ввода-вывода, начальным каталогом и окружением.
+// Все состояние интерпретатора хранится в Shell, а потоки, каталог и окружение
+// передаются запущенным программам явно, поэтому несколько Shell могут выполняться
+// одновременно
+type Shell struct {
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Dir - начальный каталог, пустой - каталог процесса, а у интерактивного шелла - домашний
+	Dir string
+	// Env - окружение в виде "имя=значение", nil - окружение процесса
+	Env []string
+
+	// stdin, stdout и stderr - потоки, которые получают команды. Составные команды
+	// подменяют их своими перенаправлениями
+	stdin, stdout, stderr *os.File
+	// dir - текущий каталог шелла, абсолютный путь. Относительные пути команд
+	// считаются от него, а не от текущего каталога процесса
+	dir string
+
+	// vars - неэкспортированные переменные, env - экспортированные: их получают
+	// запущенные команды. exportPending - имена, отмеченные export до присваивания значения
+	vars, env     map[string]string
+	exportPending map[string]bool
+	// functions - функции, определенные в шелле, aliases - псевдонимы команд
+	functions map[string]*functionDef
+	aliases   map[string]string
+	options   shellOptions
+	// name - $0: имя скрипта или самого шелла, positional - $1, $2, ...
+	name       string
+	positional []string
+
+	// flow - прерывание, которое еще не дошло до своего цикла или функции
+	flow flowState
+	// loops - глубина вложенных циклов, calls - вызовов функций и source,
+	// conditions - условий if, while и && ||, в которых не действует set -e
+	loops, calls, conditions int
+	// frames - переменные, объявленные local, по одному кадру на вызов функции
+	frames []map[string]savedVar
+
+	// lastStatus - $?, код возврата последней стадии последнего конвейера.
+	// substitutionStatus - код последней подстановки команды, он становится кодом
+	// команды из одних присваиваний, как x=$(false)
+	lastStatus, substitutionStatus int
+	// lastBackgroundPid - $!, pid последнего процесса последнего фонового задания.
+	// pid - $$: pid шелла, а в дочернем шелле стадии конвейера - pid родителя
+	lastBackgroundPid, pid int
+
+	hash *commandHash
+	// limits - ограничения, заданные ulimit. Сам шелл работает без них: их получает
+	// каждый запущенный им процесс, а от него - его потомки
+	limits map[int]syscall.Rlimit
+	// timers - замеры time, которые идут в шелле. Их получают все задания, запущенные
+	// за время замера, в том числе командами функций и составных команд
+	timers   []*cpuTimer
+	jobs     *jobTable
+	terminal terminal
+	// history - история интерактивного сеанса, ее показывает builtin history
+	history *history
+}
+
+// Run выполняет шелл с аргументами командной строки, как dev08 args, и возвращает код выхода:
+//
+//	dev08 [-ex] [-c command [name args...] | script args...]
+//
+// Без команды и скрипта команды читаются из Stdin
+func (sh *Shell) Run(args []string) (status int) {
+	streams, err := sh.streams()
+	if err != nil {
+		if sh.Stderr != nil {
+			fmt.Fprintln(sh.Stderr, err)
+		}
+		return 1
+	}
+	defer streams.close()
+	if err = sh.init(streams); err != nil {
+		fmt.Fprintln(sh.stderr, err)
+		return 1
+	}
+	watchJobs(sh.jobs)
+	defer unwatchJobs(sh.jobs)
+
+	defer func() {
+		// exit и set -e завершают шелл паникой exitShell
+		if r := recover(); r != nil {
+			code, ok := r.(exitShell)
+			if !ok {
+				panic(r)
+			}
+			status = int(code)
+		}
+	}()
+	sh.restoreParent()
+	return sh.start(args)
+}
+
+// init задает начальное состояние шелла: потоки, каталог и окружение
+func (sh *Shell) init(streams *shellStreams) error {
+	sh.stdin, sh.stdout, sh.stderr = streams.files[0], streams.files[1], streams.files[2]
+	sh.vars, sh.env, sh.exportPending = map[string]string{}, map[string]string{}, map[string]bool{}
+	sh.functions, sh.aliases = map[string]*functionDef{}, map[string]string{}
+	sh.options, sh.name, sh.positional = shellOptions{coreutils: true}, os.Args[0], nil
+	sh.flow, sh.loops, sh.calls, sh.conditions, sh.frames = flowState{}, 0, 0, 0, nil
+	sh.lastStatus, sh.substitutionStatus, sh.lastBackgroundPid, sh.pid = 0, 0, 0, os.Getpid()
+	sh.hash = &commandHash{paths: map[string]string{}}
+	sh.limits, sh.timers = map[int]syscall.Rlimit{}, nil
+	sh.jobs, sh.terminal, sh.history = newJobTable(), terminal{}, &history{}
+
+	env := sh.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		sh.env[name] = value
+	}
+
+	if sh.Dir == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		sh.dir = dir
+		return nil
+	}
+	dir, err := filepath.Abs(sh.Dir)
+	if err == nil {
+		err = checkDir(dir)
+	}
+	if err != nil {
+		return err
+	}
+	sh.dir = dir
+	sh.setVar("PWD", dir)
+	return nil
+}
+
+func (sh *Shell) start(args []string) int {
+	std := stdio{sh.stdin, sh.stdout, sh.stderr}
+	for len(args) > 0 && len(args[0]) > 1 && (args[0][0] == '-' || args[0][0] == '+') && args[0] != "-c" {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if status := sh.setCommand(args[:1], std); status != 0 {
+			return status
+		}
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "-c" {
+		if len(args) < 2 {
+			fmt.Fprintln(sh.stderr, "-c: option requires an argument")
+			return 2
+		}
+		if len(args) > 2 {
+			sh.name, sh.positional = args[2], args[3:]
+		}
+		return sh.runSource(strings.NewReader(args[1]))
+	}
+	if len(args) > 0 {
+		return sh.runScript(args[0], args[1:])
+	}
+
+	if sh.Dir == "" {
+		home, ok := sh.tildeHome("")
+		if !ok {
+			fmt.Fprintln(sh.stderr, "cannot find home directory")
+			return 1
+		}
+		if err := checkDir(home); err != nil {
+			fmt.Fprintln(sh.stderr, err)
+			return 1
+		}
+		sh.dir = home
+		sh.setVar("PWD", home)
+	}
+
+	sh.initJobControl()
+	sh.loadRC()
+	// о завершенных фоновых заданиях сообщается только в интерактивном режиме
+	var notifications io.Writer = io.Discard
+	if sh.terminal.enabled {
+		notifications = sh.stderr
+	}
+	// редактор строки нужен только на терминале, иначе ввод читается построчно
+	var input lineReader = plainReader{in: bufio.NewReader(sh.stdin), out: sh.stdout}
+	if isTerminal(int(sh.stdin.Fd())) {
+		sh.history = sh.loadHistory()
+		input = newEditor(int(sh.stdin.Fd()), sh.stdout, sh.history, sh.completions)
+	}
+	for {
+		sh.jobs.report(notifications, true)
+		list, err := sh.readCommand(input, sh.prompt(time.Now()))
+		if err == io.EOF {
+			return sh.lastStatus
+		}
+		if err == errInterrupted {
+			sh.lastStatus = 130
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(sh.stdout, err)
+			sh.lastStatus = 2
+			continue
+		}
+		sh.runList(list)
+		// break вне цикла или Ctrl+C не должны прервать следующую команду
+		sh.flow.kind = flowNone
+	}
+}
+
+// shellStreams - файлы, которые станут stdin, stdout и stderr шелла. Запущенным
+// программам нужны настоящие дескрипторы, поэтому io.Reader и io.Writer, которые
+// не *os.File, подключаются через каналы
+type shellStreams struct {
+	files [3]*os.File
+	// closers закрывают каналы и ждут, пока их содержимое будет скопировано
+	closers []func()
+}
+
+func (sh *Shell) streams() (*shellStreams, error) {
+	s := &shellStreams{}
+	if err := s.input(sh.Stdin); err != nil {
+		return nil, err
+	}
+	if err := s.output(1, sh.Stdout); err != nil {
+		s.close()
+		return nil, err
+	}
+	// как в exec.Cmd: общий Writer получает один канал, иначе два копирования
+	// писали бы в него одновременно
+	if sameWriter(sh.Stdout, sh.Stderr) {
+		s.files[2] = s.files[1]
+		return s, nil
+	}
+	if err := s.output(2, sh.Stderr); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sameWriter сравнивает Writer-ы, которые могут быть несравнимыми типами
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() { recover() }()
+	return a != nil && a == b
+}
+
+func (s *shellStreams) input(r io.Reader) error {
+	if f, ok := r.(*os.File); ok {
+		s.files[0] = f
+		return nil
+	}
+	if r == nil {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			return err
+		}
+		s.files[0] = f
+		s.closers = append(s.closers, func() { f.Close() })
+		return nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		io.Copy(pw, r)
+		pw.Close()
+	}()
+	s.files[0] = pr
+	s.closers = append(s.closers, func() { pr.Close() })
+	return nil
+}
+
+func (s *shellStreams) output(fd int, w io.Writer) error {
+	if f, ok := w.(*os.File); ok {
+		s.files[fd] = f
+		return nil
+	}
+	if w == nil {
+		w = io.Discard
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(w, pr)
+		pr.Close()
+		close(done)
+	}()
+	s.files[fd] = pw
+	s.closers = append(s.closers, func() {
+		pw.Close()
+		<-done
+	})
+	return nil
+}
+
+func (s *shellStreams) close() {
+	for _, c := range s.closers {
+		c()
+	}
+}
+
+// path - путь name относительно текущего каталога шелла. Путь не очищается:
+// .. после символической ссылки ведет туда же, куда и от текущего каталога процесса
+func (sh *Shell) path(name string) string {
+	return resolvePath(sh.dir, name)
+}
+
+func resolvePath(dir, name string) string {
+	if dir == "" || name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return strings.TrimSuffix(dir, "/") + "/" + name
+}
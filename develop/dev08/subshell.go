@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"os"
+	"strings"
+)
+
+// clone - копия шелла, как после fork: переменные, функции, каталог и флаги копии
+// меняются независимо от шелла, а задания и история остаются общими.
+// Управление заданиями в копии выключено, как в подоболочке sh
+func (sh *Shell) clone() *Shell {
+	sub := *sh
+	sub.vars, sub.env, sub.exportPending = maps.Clone(sh.vars), maps.Clone(sh.env), maps.Clone(sh.exportPending)
+	sub.functions, sub.aliases, sub.limits = maps.Clone(sh.functions), maps.Clone(sh.aliases), maps.Clone(sh.limits)
+	sub.terminal.enabled = false
+	return &sub
+}
+
+// subshell выполняет fn как подоболочку: fn получает копию шелла, поэтому смена
+// каталога и переменных внутри нее не видна шеллу
+func (sh *Shell) subshell(fn func(sub *Shell)) {
+	sub := sh.clone()
+	defer func() {
+		// exit в подоболочке завершает только ее
+		if r := recover(); r != nil {
+			code, ok := r.(exitShell)
+			if !ok {
+				panic(r)
+			}
+			sub.lastStatus = int(code)
+		}
+		sh.lastStatus = sub.lastStatus
+		// Ctrl+C прерывает и команды после подоболочки
+		if sub.flow.kind == flowInterrupt {
+			sh.flow.kind = flowInterrupt
+		}
+	}()
+	fn(sub)
+}
+
+// commandSubstitution выполняет script в подоболочке и возвращает его stdout
+// без завершающих переводов строк
+func (sh *Shell) commandSubstitution(script string) (string, error) {
+	list, err := parse(script, sh.aliases)
+	if err != nil {
+		return "", err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	output := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		r.Close()
+		output <- b
+	}()
+	sh.subshell(func(sub *Shell) {
+		sub.stdout = w
+		sub.runList(list)
+	})
+	sh.substitutionStatus = sh.lastStatus
+	w.Close()
+	return strings.TrimRight(string(<-output), "\n"), nil
+}
@@ -1,15 +1,12 @@
 package main
 
 import (
-	"bufio"
-	"errors"
 	"fmt"
-	"io/fs"
-	"log"
+	"io"
 	"os"
-	"os/exec"
-	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 /*
@@ -36,218 +33,81 @@ import (
 */
 
 func main() {
-	home, errr := os.UserHomeDir()
-	if errr != nil {
-		log.Fatal(errr)
-	}
-	if os.Chdir(home) != nil {
-		log.Fatal(errr)
-	}
-
-	currentDir := home
-
-	var input string
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print(currentDir, "> $ ")
-
-		b, err := reader.ReadByte()
-		if b == 0 && err != nil {
-			log.Println(err)
-			os.Exit(0)
-		}
-		reader.UnreadByte()
-		input, err = reader.ReadString('\n')
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		if input != "" {
-			input = strings.TrimSuffix(input, "\n")
-			input = strings.TrimSpace(input)
-			args := strings.Split(input, " ")
-			if len(args) >= 1 {
-				input = args[0]
-				args = args[1:]
-			}
-			switch input {
-			case "ps":
-				ps(args)
-			case "kill":
-				if err = kill(args); err != nil {
-					fmt.Println(err)
-				}
-			case "echo":
-				echo(args)
-			case "pwd":
-				pwd()
-			case "cd":
-				if len(args) > 1 {
-					fmt.Println("Too many arguments")
-				} else if len(args) == 1 {
-					if err := cd(args[0], currentDir); err != nil {
-						fmt.Println(err)
-					}
-				}
-				currentDir, err = os.Getwd()
-				if err != nil {
-					log.Fatal(err)
-				}
-			case `\quit`:
-				os.Exit(0)
-			case "":
-				continue
-			default:
-				p, err := forkexec(input, args)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				p.Wait()
-			}
-			input = ""
-			err = nil
-		}
-	}
+	execWithLimits()
+	sh := &Shell{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	os.Exit(sh.Run(os.Args[1:]))
 }
 
-func echo(args []string) {
-	env := os.Environ()
-	for _, arg := range args {
-		if arg[0:1] == "$" {
-			arg = strings.Trim(arg, "$")
-			for _, envArg := range env {
-				envArg = strings.TrimSuffix(envArg, "\n")
-				envArg = strings.TrimSpace(envArg)
-				splitted := strings.Split(envArg, "=")
-				if arg == splitted[0] {
-					fmt.Print(splitted[1], " ")
-				}
+// readCommand читает строки, пока из них не сложится законченная команда:
+// незакрытые кавычки и висящие |, && и || продолжаются на следующей строке
+func (sh *Shell) readCommand(reader lineReader, prompt string) (*commandList, error) {
+	input := ""
+	for {
+		line, err := reader.readLine(prompt)
+		if err != nil && (err != io.EOF || line == "") {
+			if input != "" && err == io.EOF {
+				return nil, errIncomplete
 			}
-		} else {
-			fmt.Print(arg, " ")
+			return nil, err
 		}
-	}
-	fmt.Println()
-}
-
-func pwd() {
-	result, err := os.Getwd()
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	fmt.Println(result)
-
-}
-
-func cd(path string, currentDir string) error {
-	dir := currentDir
-	if path == ".." {
-		if currentDir == "/" {
-			return errors.New("cannot go up")
+		input += line
+		list, err := parse(input, sh.aliases)
+		if err != errIncomplete {
+			return list, err
 		}
-		currentDir = strings.TrimSuffix(currentDir, "/")
-		splitted := strings.Split(currentDir, "/")
-		dir = strings.Join(splitted[:len(splitted)-1], "/")
-		fmt.Println(dir)
-	} else if path == "." {
-		return nil
-	} else {
-		firstTry := currentDir + "/" + path
-		secondTry := path
-		if _, err := os.Stat(firstTry); err == nil {
-			dir = firstTry
-		} else if _, err = os.Stat(secondTry); err == nil {
-			dir = secondTry
-		} else {
-			return errors.New("no such file or directory")
+		prompt = "> "
+		if ps2, ok := sh.lookupVar("PS2"); ok {
+			prompt = sh.expandPrompt(ps2, time.Now())
 		}
 	}
-	if err := os.Chdir(dir); err != nil {
-		return err
-	}
-	return nil
 }
 
-func ps(args []string) {
-	cmd := exec.Command("ps", args...)
-	psOut, err := cmd.Output()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	fmt.Println(string(psOut))
+func echo(args []string, stdout io.Writer) {
+	fmt.Fprintln(stdout, strings.Join(args, " "))
 }
 
-func kill(args []string) error {
-	for _, arg := range args {
-		pid, err := strconv.Atoi(arg)
-		if err != nil {
-			return err
-		}
-		process, err := os.FindProcess(pid)
-		if err != nil {
-			return err
-		}
-		if err = process.Kill(); err != nil {
-			return err
-		}
+// forkexec находит исполняемый файл по имени команды и запускает его с переданными
+// stdin, stdout и stderr. Процесс получает окружение, текущий каталог шелла и ограничения ulimit
+func (sh *Shell) forkexec(name string, args []string, files []*os.File, env []string, sys *syscall.SysProcAttr) (*os.Process, error) {
+	path, err := sh.lookPath(name)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	path, env = sh.withLimits(sh.path(path), env)
+	return sh.startProcess(path, append([]string{name}, args...), files, env, sys)
 }
 
-func forkexec(input string, args []string) (*os.Process, error) {
-	if input[:2] == "./" {
-		currentDir, err := os.Getwd()
-		if err != nil {
-			return nil, err
-		}
-		path := currentDir + "/" + input[2:]
-		if err = checkFileExecutable(path); err != nil {
-			return nil, err
-		}
-		return startProcess(path, args)
-	} else {
-		if err := checkFileExecutable(input); err != nil {
-			return nil, err
-		}
-		return startProcess(input, args)
+func (sh *Shell) startProcess(pathToFile string, args []string, files []*os.File, env []string, sys *syscall.SysProcAttr) (*os.Process, error) {
+	procAttr := &os.ProcAttr{
+		Dir:   sh.dir,
+		Env:   env,
+		Files: files,
+		Sys:   sys,
 	}
-}
-
-func startProcess(pathToFile string, args []string) (*os.Process, error) {
-	env := os.Environ()
-	var procAttr os.ProcAttr
-	procAttr.Files = []*os.File{os.Stdin,
-		os.Stdout, os.Stderr}
-	procAttr.Env = env
-	p, err := os.StartProcess(pathToFile, args, &os.ProcAttr{})
+	p, err := os.StartProcess(pathToFile, args, procAttr)
 	if err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
+// checkFileExecutable проверяет, что file - обычный файл (или ссылка на него),
+// который текущий пользователь может запустить
 func checkFileExecutable(file string) error {
-	absoluteFile := file
-
-	fileInfo, err := os.Stat(absoluteFile)
+	fileInfo, err := os.Stat(file)
 	if err != nil {
 		return err
 	}
 	m := fileInfo.Mode()
 
-	if !((m.IsRegular()) || (uint32(m&fs.ModeSymlink) == 0)) {
-		return errors.New("File " + absoluteFile + " is not a normal file or symlink.")
+	if !m.IsRegular() {
+		return notExecutableError{file, "is not a regular file"}
 	}
-	if uint32(m&0111) == 0 {
-		return errors.New("File " + absoluteFile + " is not executable.")
+	if m&0111 == 0 {
+		return notExecutableError{file, "is not executable"}
 	}
-	if uint32(m&0100) == 0 || uint32(m&0010) == 0 {
-		return errors.New("File " + absoluteFile + " is not executable by this user.")
+	if err = syscall.Access(file, accessExecute); err != nil {
+		return notExecutableError{file, "is not executable by this user"}
 	}
-
 	return nil
-
 }
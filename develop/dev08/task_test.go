@@ -1 +1,1213 @@
 package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// TestMain позволяет тестовому бинарнику работать дочерним шеллом и посредником
+// ulimit: так шелл выполняет составные команды в конвейерах и команды с ограничениями
+func TestMain(m *testing.M) {
+	if _, ok := os.LookupEnv(limitsVar); ok || (len(os.Args) > 1 && os.Args[1] == "-c") {
+		main()
+	}
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+// newShell - шелл для тестов с каталогом dir и окружением процесса, как в Run.
+// Его вывод отбрасывается
+func newShell(t *testing.T, dir string) *Shell {
+	t.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { devNull.Close() })
+	sh := &Shell{Stdin: devNull, Stdout: devNull, Stderr: devNull, Dir: dir}
+	streams, err := sh.streams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sh.init(streams); err != nil {
+		t.Fatal(err)
+	}
+	watchJobs(sh.jobs)
+	t.Cleanup(func() { unwatchJobs(sh.jobs) })
+	return sh
+}
+
+func Test_Shell_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantOut    string
+		wantStatus int
+	}{
+		{"Command", []string{"-c", `echo "$0 $@"; exit 4`, "name", "a", "b"}, "", "name a b\n", 4},
+		{"Stdin", nil, "cd /\npwd\nfalse\n", "$ $ /\n$ $ ", 1},
+		{"Env", []string{"-c", "echo $FROM_TEST"}, "", "value\n", 0},
+		{"Bad option", []string{"-q"}, "", "", 2},
+		{"Unset parameter is fatal", []string{"-c", "echo ${NO_SUCH_VAR:?}; echo after"}, "", "", 1},
+		{"Unset parameter in pipeline", []string{"-c", "echo ${NO_SUCH_VAR:?} | cat; echo after"}, "", "after\n", 0},
+		{"Pipeline stage sees $?", []string{"-c", "f() { :; }; false; { echo $?; } | cat"}, "", "1\n", 0},
+		{"Pipeline stage sees $$ and $!", []string{"-c", `sleep 0 & pids="$$ $!"; { test "$$ $!" = "$pids" && echo same; } | cat`}, "", "same\n", 0},
+		{"Unset parameter in script from stdin", nil, "echo ${NO_SUCH_VAR:?}\necho after\n", "$ ", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			sh := &Shell{Stdin: strings.NewReader(tt.stdin), Stdout: &out, Dir: t.TempDir(), Env: []string{"FROM_TEST=value", "PS1=$ ", "PATH=" + os.Getenv("PATH")}}
+			if status := sh.Run(tt.args); status != tt.wantStatus {
+				t.Errorf("Run() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+	if os.Getenv("FROM_TEST") != "" {
+		t.Error("Run() leaked its environment into the process")
+	}
+}
+
+// Test_Shell_Run_concurrent запускает шеллы с разными каталогами и окружением одновременно:
+// ни один не должен увидеть каталог, переменные или потоки другого
+func Test_Shell_Run_concurrent(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := `[ "$(pwd)" = "$START" ] || echo "pwd $(pwd)"
+echo $NAME > out; read name < out; [ "$name" = "$NAME" ] || echo "read $name"
+child=$(/bin/sh -c pwd); [ "$child" = "$START" ] || echo "child $child"
+mkdir sub; cd sub; [ -d ../sub ] || echo "cd $(pwd)"
+echo done`
+	const shells = 8
+	outs := make([]bytes.Buffer, shells)
+	statuses := make([]int, shells)
+	dirs := make([]string, shells)
+	done := make(chan struct{})
+	for i := 0; i < shells; i++ {
+		dirs[i], err = filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 5; j++ {
+				sh := &Shell{Stdout: &outs[i], Stderr: &outs[i], Dir: dirs[i],
+					Env: []string{"NAME=shell" + strconv.Itoa(i), "START=" + dirs[i], "PATH=" + os.Getenv("PATH")}}
+				if statuses[i] = sh.Run([]string{"-c", "cd $START; rm -rf sub; " + script}); statuses[i] != 0 {
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < shells; i++ {
+		<-done
+	}
+	for i := 0; i < shells; i++ {
+		if want := strings.Repeat("done\n", 5); statuses[i] != 0 || outs[i].String() != want {
+			t.Errorf("shell %d: Run() = %v, output %q, want 0, %q", i, statuses[i], outs[i].String(), want)
+		}
+	}
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("Run() changed the process directory to %v", got)
+	}
+}
+
+// Test_scripts выполняет testdata/*.sh в Shell и сравнивает stdout, stderr и код выхода
+// с testdata/*.golden. С флагом -update golden-файлы перезаписываются
+func Test_scripts(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".sh")
+		t.Run(name, func(t *testing.T) {
+			path, err := filepath.Abs(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stdout, stderr bytes.Buffer
+			sh := &Shell{
+				Stdin:  strings.NewReader(""),
+				Stdout: &stdout,
+				Stderr: &stderr,
+				Dir:    dir,
+				Env:    []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir},
+			}
+			status := sh.Run([]string{path})
+
+			got := fmt.Sprintf("-- stdout --\n%s-- stderr --\n%s-- status --\n%d\n", &stdout, &stderr, status)
+			got = strings.ReplaceAll(got, dir, "$DIR")
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v, run go test -update to create golden files", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func Test_lex(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{"Repeated spaces", "echo   a    b", []string{"echo", "a", "b"}, nil},
+		{"Double quotes keep spaces", `echo "hello  world"`, []string{"echo", "hello  world"}, nil},
+		{"Single quotes", `echo 'single "quotes"'`, []string{"echo", `single "quotes"`}, nil},
+		{"Escapes", `echo a\ b \"c\"`, []string{"echo", "a b", `"c"`}, nil},
+		{"Escapes in double quotes", `echo "a\"b\\c\d"`, []string{"echo", `a"b\c\d`}, nil},
+		{"Adjacent quotes form one word", `echo "a"'b'c`, []string{"echo", "abc"}, nil},
+		{"Empty quotes are an argument", `echo "" ''`, []string{"echo", "", ""}, nil},
+		{"Comment", "echo a # comment", []string{"echo", "a"}, nil},
+		{"Hash inside a word", "echo a#b", []string{"echo", "a#b"}, nil},
+		{"Operators without spaces", "a|b&&c||d;e", []string{"a", "|", "b", "&&", "c", "||", "d", ";", "e"}, nil},
+		{"Line continuation", "echo a\\\nb", []string{"echo", "ab"}, nil},
+		{"Unterminated double quote", `echo "abc`, nil, errIncomplete},
+		{"Unterminated single quote", `echo 'abc`, nil, errIncomplete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := lex(tt.input)
+			if err != tt.wantErr {
+				t.Fatalf("lex() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]string, 0, len(tokens))
+			for _, tok := range tokens {
+				switch tok.kind {
+				case tokEOF:
+				case tokWord:
+					got = append(got, tok.word.String())
+				default:
+					got = append(got, tok.kind.String())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lex() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][][]string
+		wantErr bool
+	}{
+		{"Pipeline", "ls -la | grep go", [][][]string{{{"ls", "-la"}, {"grep", "go"}}}, false},
+		{"List", "cd /tmp; pwd\necho ok", [][][]string{{{"cd", "/tmp"}}, {{"pwd"}}, {{"echo", "ok"}}}, false},
+		{"And-or", "true && echo a || echo b", [][][]string{{{"true"}}, {{"echo", "a"}}, {{"echo", "b"}}}, false},
+		{"Trailing semicolon", "echo a;", [][][]string{{{"echo", "a"}}}, false},
+		{"Empty input", "  # only comment", nil, false},
+		{"Empty pipeline stage", "ls | | wc", nil, true},
+		{"Leading operator", "&& ls", nil, true},
+		{"Double semicolon", "ls ;; pwd", nil, true},
+		{"Quit command", `\quit`, [][][]string{{{`\quit`}}}, false},
+		{"Redirects are not arguments", "sort < in > out 2>&1 | wc", [][][]string{{{"sort"}, {"wc"}}}, false},
+		{"Redirect without target", "echo a >", nil, true},
+		{"Background", "sleep 1 & echo a", [][][]string{{{"sleep", "1"}}, {{"echo", "a"}}}, false},
+		{"Heredoc body is not a command", "cat <<EOF\nls | wc\nEOF\npwd", [][][]string{{{"cat"}}, {{"pwd"}}}, false},
+	}
+	sh := newShell(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse(tt.input, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got [][][]string
+			for _, item := range list.items {
+				for _, pl := range item.pipelines {
+					var stages [][]string
+					for _, cmd := range pl.commands {
+						_, args, err := sh.expand(cmd.(*simpleCommand))
+						if err != nil {
+							t.Fatal(err)
+						}
+						stages = append(stages, args)
+					}
+					got = append(got, stages)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseIncomplete(t *testing.T) {
+	for _, input := range []string{"ls |", "true &&", "false ||\n", `echo "a`, "cat <<EOF", "cat <<EOF\nline\n",
+		"echo a\\\n", "echo a \\\n", `echo "a\` + "\n"} {
+		if _, err := parse(input, nil); err != errIncomplete {
+			t.Errorf("parse(%q) error = %v, want %v", input, err, errIncomplete)
+		}
+	}
+}
+
+func Test_parseCompound(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"If", "if true\nthen echo a\nelif false; then echo b\nelse echo c\nfi", "if true; then echo a; elif false; then echo b; else echo c; fi;", nil},
+		{"While with redirect", "while read l; do echo $l; done < in", "while read l; do echo $l; done <in;", nil},
+		{"Until", "until false; do :; done", "until false; do :; done;", nil},
+		{"For", "for x in a 'b c'; do echo \"$x\"; done", "for x in a 'b c'; do echo \"$x\"; done;", nil},
+		{"For without in", "for x\ndo echo $x\ndone", "for x; do echo $x; done;", nil},
+		{"Case", "case $f in\n*.go|*.c) echo src;;\n(*) ;;\nesac", "case $f in *.go | *.c) echo src; ;; *)  ;; esac;", nil},
+		{"Function", "f() {\n\tlocal x=1\n}", "f() { local x=1; };", nil},
+		{"Function keyword", "function f { echo; } > out", "f() { echo; } >out;", nil},
+		{"Compound in pipeline", "{ echo a & } | cat", "{ echo a & } | cat;", nil},
+		{"Heredoc", "cat <<EOF\n$x \"y\"\nEOF", `cat <<<"$x \"y\"";`, nil},
+		{"Keywords as arguments", "echo if then fi", "echo if then fi;", nil},
+		{"Subshell", "(cd /; pwd) > out", "( cd /; pwd; ) >out;", nil},
+		{"Subshell in pipeline", "(echo b\necho a) | sort", "( echo b; echo a; ) | sort;", nil},
+		{"Nested subshell", "( (true) )", "( ( true; ); );", nil},
+		{"Negation", "! grep -q x f && echo no", "! grep -q x f && echo no;", nil},
+		{"Quoted bang", "'!' x", "'!' x;", nil},
+		{"Time", "time -p ! sleep 1 | cat && time f", "time -p ! sleep 1 | cat && time f;", nil},
+		{"Time without command", "time; time -p", "time; time -p;", nil},
+		{"Unfinished subshell", "(echo", "", errIncomplete},
+		{"Empty subshell", "( )", "", syntaxError{}},
+		{"Unfinished if", "if true; then echo", "", errIncomplete},
+		{"Unfinished case", "case x in\na) echo", "", errIncomplete},
+		{"Empty condition", "if then echo; fi", "", syntaxError{}},
+		{"Stray fi", "echo; fi", "", syntaxError{}},
+		{"Word after done", "while true; do :; done x", "", syntaxError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse(tt.input, nil)
+			if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+				t.Fatalf("parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := list.String(); got != tt.want {
+				t.Errorf("parse().String() = %q, want %q", got, tt.want)
+			}
+			if _, err := parse(list.String(), nil); err != nil {
+				t.Errorf("String() does not parse back: %v", err)
+			}
+		})
+	}
+}
+
+func Test_runList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"Status of the last stage", "/bin/false | /bin/true", 0},
+		{"Failing last stage", "/bin/true | /bin/false", 1},
+		{"Builtin feeds a process", "echo hi | /bin/cat", 0},
+		{"Unknown command", "/no/such/cmd", 127},
+		{"Not executable", "/etc/passwd", 126},
+		{"Found in PATH", "true", 0},
+		{"And after failure is skipped", "/bin/false && /bin/true", 1},
+		{"Or after failure runs", "/bin/false || /bin/true", 0},
+		{"Or after success is skipped", "/bin/true || /bin/false", 0},
+		{"Chain", "/bin/false && /bin/true || /bin/false", 1},
+		{"List returns last status", "/bin/false; /bin/true", 0},
+		{"Background does not wait", "/bin/false &", 0},
+		{"Wait returns job status", "/bin/sh -c 'exit 3' & wait %+", 3},
+		{"Background list", "/bin/false || /bin/sh -c 'exit 4' & wait %%", 4},
+		{"Wait without jobs", "wait", 0},
+		{"Wait for unknown job", "wait %9", 127},
+		{"Signaled process", "/bin/sh -c 'kill -TERM $$'", 143},
+	}
+	sh := newShell(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse(tt.input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sh.runList(list)
+			if sh.lastStatus != tt.want {
+				t.Errorf("status = %v, want %v", sh.lastStatus, tt.want)
+			}
+		})
+	}
+}
+
+func Test_runSource(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"Last status", "/bin/false\n/bin/true\n", 0},
+		{"Exit code", "exit 3\n/bin/true\n", 3},
+		{"Exit without argument", "/bin/false\nexit\n", 1},
+		{"Errexit", "set -e\n/bin/false\n/bin/true\n", 1},
+		{"Errexit ignores conditions", "set -e\n/bin/false || /bin/true\n/bin/false && /bin/true\n/bin/true\n", 0},
+		{"Errexit after and", "set -e\n/bin/true && /bin/false\n/bin/true\n", 1},
+		{"Assignment takes substitution status", "x=$(exit 4)\n", 4},
+		{"Shift", "set -- a b\nshift\n/bin/sh -c 'exit $1' x $#\n", 1},
+		{"Shift too far", "shift 2\n", 1},
+		{"Invalid option", "set -q\n", 2},
+		{"Syntax error", "echo |\n|\n/bin/true\n", 2},
+		{"Function return", "f() { return 5; /bin/true; }\nf\n", 5},
+		{"Function arguments", "f() { return $#; }\nf a 'b c'\n", 2},
+		{"Local is restored", "x=1\nf() { local x=2; }\nf\ntest $x = 1\n", 0},
+		{"Break", "for i in 1 2 3; do [ $i = 2 ] && break; done\nexit $i\n", 2},
+		{"Continue", "n=0\nfor i in 1 2 3; do [ $i = 2 ] && continue; n=$n$i; done\nexit $n\n", 13},
+		{"Break out of two loops", "for i in 1 2; do while true; do break 2; done; exit 9; done\nexit $i\n", 1},
+		{"While", "n=\nwhile [ ${#n} -lt 3 ]; do n=x$n; done\nexit ${#n}\n", 3},
+		{"Case", "case abc in a) exit 1;; a*) exit 2;; *) exit 3;; esac\n", 2},
+		{"Quoted case pattern", "case abc in 'a*') exit 1;; *) exit 3;; esac\n", 3},
+		{"Errexit ignores if condition", "set -e\nif /bin/false; then :; fi\n/bin/true\n", 0},
+		{"Loop in pipeline runs in a child shell", "echo 7 | while read n; do exit $n; done\n", 7},
+		{"Function in pipeline", "f() { read n; return $n; }\necho 6 | f\n", 6},
+		{"Return outside function", "return 3\n", 1},
+	}
+	sh := newShell(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// в подоболочке exit и set -e не завершают тест
+			sh.subshell(func(sub *Shell) { sub.runSource(strings.NewReader(tt.input)) })
+			if sh.lastStatus != tt.want {
+				t.Errorf("status = %v, want %v", sh.lastStatus, tt.want)
+			}
+		})
+	}
+}
+
+func Test_evalTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    bool
+		wantErr bool
+	}{
+		{"No arguments", nil, false, false},
+		{"Non-empty string", []string{"x"}, true, false},
+		{"Empty string", []string{""}, false, false},
+		{"Operator as a string", []string{"-n"}, true, false},
+		{"Negation", []string{"!", ""}, true, false},
+		{"Zero length", []string{"-z", ""}, true, false},
+		{"Strings equal", []string{"a", "=", "a"}, true, false},
+		{"Strings differ", []string{"a", "!=", "a"}, false, false},
+		{"Numbers", []string{"10", "-gt", "9"}, true, false},
+		{"Not a number", []string{"ten", "-gt", "9"}, false, true},
+		{"Directory", []string{"-d", os.TempDir()}, true, false},
+		{"Missing file", []string{"-e", "/no/such/file"}, false, false},
+		{"And", []string{"-n", "a", "-a", "-z", "b"}, false, false},
+		{"Or", []string{"-n", "a", "-o", "-z", "b"}, true, false},
+		{"Parentheses", []string{"!", "(", "a", "=", "b", ")", "-a", "x"}, true, false},
+		{"Missing operand", []string{"1", "-lt"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evalTest("", tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evalTest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("evalTest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    procInfo
+		wantErr bool
+	}{
+		{"Plain", "42 (sleep) S 1 42 42 34816 42 4194304 86 0 0 0 150 50 0 0 20 0 1 0 463053 2703360 10",
+			procInfo{pid: 42, ppid: 1, pgrp: 42, session: 42, ttyNr: 34816, tpgid: 42, threads: 1, state: 'S', comm: "sleep",
+				utime: 150, stime: 50, start: 463053, vsz: 2640, rss: 10 * int64(os.Getpagesize()) / 1024}, false},
+		{"Name with spaces and parens", "7 (a (b) c) R 1 7 7 0 -1 0 0 0 0 0 0 0 0 0 20 -5 3 0 10 0 0",
+			procInfo{pid: 7, ppid: 1, pgrp: 7, session: 7, tpgid: -1, nice: -5, threads: 3, state: 'R', comm: "a (b) c", start: 10}, false},
+		{"Truncated", "7 (x) R 1 7", procInfo{}, true},
+		{"No name", "7 x R", procInfo{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStat(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseStat() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ps(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		// wantFields - начало одной из строк вывода, поля сравниваются без учета выравнивания
+		wantFields []string
+	}{
+		{"Own process", []string{"-o", "pid=,comm="}, 0, []string{strconv.Itoa(os.Getpid())}},
+		{"Header", []string{"-e", "-o", "pid,ppid"}, 0, []string{"PID", "PPID"}},
+		{"Default columns", []string{"-f"}, 0, []string{"PID", "PPID", "USER"}},
+		{"Sort", []string{"-e", "--sort=-pid,comm", "-o", "pid"}, 0, []string{"PID"}},
+		{"Unknown option", []string{"-q"}, 1, nil},
+		{"Unknown column", []string{"-o", "pid,nope"}, 1, nil},
+		{"Unknown sort key", []string{"--sort", "nope"}, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if status := ps(tt.args, out, io.Discard); status != tt.wantStatus {
+				t.Fatalf("ps() = %v, want %v", status, tt.wantStatus)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+			for _, line := range strings.Split(out.String(), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= len(tt.wantFields) && reflect.DeepEqual(fields[:len(tt.wantFields)], tt.wantFields) {
+					return
+				}
+			}
+			t.Errorf("ps() output %q has no line starting with %q", out.String(), tt.wantFields)
+		})
+	}
+}
+
+func Test_parseSignal(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    syscall.Signal
+		wantErr bool
+	}{
+		{"9", syscall.SIGKILL, false},
+		{"0", 0, false},
+		{"TERM", syscall.SIGTERM, false},
+		{"SIGHUP", syscall.SIGHUP, false},
+		{"int", syscall.SIGINT, false},
+		{"64", 0, true},
+		{"NOPE", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseSignal(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSignal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSignal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_kill(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantOut    string
+		wantErr    string
+	}{
+		{"List by number", []string{"-l", "9", "143"}, 0, "KILL\nTERM\n", ""},
+		{"List by name", []string{"-l", "SIGUSR1"}, 0, "10\n", ""},
+		{"Bad signal", []string{"-NOPE", "1"}, 1, "", "kill: NOPE: invalid signal specification\n"},
+		{"Bad target", []string{"-0", "x", strconv.Itoa(os.Getpid())}, 1, "", "kill: x: arguments must be process or job IDs\n"},
+		{"No job", []string{"%9"}, 1, "", "kill: %9: no such job\n"},
+		{"Usage", []string{"-9"}, 2, "", ""},
+	}
+	sh := newShell(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if status := sh.kill(tt.args, stdio{out: &out, err: &errOut}); status != tt.wantStatus {
+				t.Fatalf("kill() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("kill() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if tt.wantErr != "" && errOut.String() != tt.wantErr {
+				t.Errorf("kill() stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+		})
+	}
+
+	p, err := os.StartProcess("/bin/sleep", []string{"sleep", "60"}, &os.ProcAttr{})
+	if err != nil {
+		t.Skip(err)
+	}
+	if status := sh.kill([]string{"-s", "KILL", strconv.Itoa(p.Pid)}, stdio{out: io.Discard, err: io.Discard}); status != 0 {
+		t.Fatalf("kill() = %v, want 0", status)
+	}
+	if state, _ := p.Wait(); state.Sys().(syscall.WaitStatus).Signal() != syscall.SIGKILL {
+		t.Errorf("process exited with %v, want SIGKILL", state)
+	}
+}
+
+func Test_parseInterval(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"5", 5 * time.Second, false},
+		{"0.5s", 500 * time.Millisecond, false},
+		{"1.5m", 90 * time.Second, false},
+		{"2h", 2 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"5x", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseInterval(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatTimes(t *testing.T) {
+	tests := []struct {
+		name            string
+		real, user, sys time.Duration
+		posix           bool
+		want            string
+	}{
+		{"Default", 61234567 * time.Microsecond, 1500 * time.Microsecond, 0, false, "\nreal\t1m1.235s\nuser\t0m0.002s\nsys\t0m0.000s\n"},
+		{"Posix", 61234567 * time.Microsecond, 1500 * time.Microsecond, 0, true, "real 61.23\nuser 0.00\nsys 0.00\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTimes(tt.real, tt.user, tt.sys, tt.posix); got != tt.want {
+				t.Errorf("formatTimes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ulimit(t *testing.T) {
+	sh := newShell(t, "")
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil || lim.Max < 64 {
+		t.Skip("no room for RLIMIT_NOFILE", err)
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantOut    string
+		wantErr    string
+	}{
+		{"Set both", []string{"-n", "64"}, 0, "", ""},
+		{"Print soft", []string{"-n"}, 0, "64\n", ""},
+		{"Print hard", []string{"-Hn"}, 0, "64\n", ""},
+		{"Lower soft", []string{"-S", "-n", "32"}, 0, "", ""},
+		{"Print both", []string{"-SHn"}, 0, "32\n", ""},
+		{"Soft above hard", []string{"-Sn", "100"}, 1, "", "ulimit: open files: cannot modify limit: invalid argument\n"},
+		{"Soft from hard", []string{"-Sn", "hard"}, 0, "", ""},
+		{"Units", []string{"-f", "2"}, 0, "", ""},
+		{"Default resource", nil, 0, "2\n", ""},
+		{"Bad number", []string{"-t", "x"}, 1, "", "ulimit: cpu time: x: invalid number\n"},
+		{"Bad option", []string{"-q"}, 2, "", ""},
+		{"Too many", []string{"-n", "1", "2"}, 2, "", "ulimit: too many arguments\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if status := sh.ulimit(tt.args, stdio{out: &out, err: &errOut}); status != tt.wantStatus {
+				t.Fatalf("ulimit() = %v, want %v: %s", status, tt.wantStatus, errOut.String())
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("ulimit() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if tt.wantErr != "" && errOut.String() != tt.wantErr {
+				t.Errorf("ulimit() stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+		})
+	}
+	if got := sh.limits[syscall.RLIMIT_FSIZE].Cur; got != 2048 {
+		t.Errorf("RLIMIT_FSIZE = %v, want 2048", got)
+	}
+}
+
+func Test_lookPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sh := newShell(t, "")
+	sh.setVar("PATH", "/no/such/dir:"+dir)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Found in PATH", "tool", filepath.Join(dir, "tool"), false},
+		{"Not executable", "data", "", true},
+		{"Missing", "nosuch", "", true},
+		{"Path is not searched", filepath.Join(dir, "tool"), filepath.Join(dir, "tool"), false},
+		{"Directory", dir, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sh.lookPath(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("lookPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	sh.setVar("PATH", "/no/such/dir")
+	if _, err := sh.lookPath("tool"); err == nil {
+		t.Error("lookPath() after PATH change returned stale hashed path")
+	}
+}
+
+func Test_typeCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sh := newShell(t, "")
+	sh.setVar("PATH", dir)
+
+	tests := []struct {
+		name       string
+		args       []string
+		want       string
+		wantStatus int
+	}{
+		{"Builtin", []string{"echo"}, "echo is a shell builtin\n", 0},
+		{"Shell builtin", []string{"cd"}, "cd is a shell builtin\n", 0},
+		{"External", []string{"tool"}, "tool is " + filepath.Join(dir, "tool") + "\n", 0},
+		{"Hashed", []string{"tool"}, "tool is hashed (" + filepath.Join(dir, "tool") + ")\n", 0},
+		{"Not found", []string{"nosuch"}, "type: nosuch: not found\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if status := sh.typeCommand(tt.args, stdio{out: &out, err: &out}); status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.want {
+				t.Errorf("typeCommand() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_redirect(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		name  string
+		input string
+		file  string
+		want  string
+	}{
+		{"Builtin to file", "echo hi > " + file("out"), "out", "hi\n"},
+		{"Append", "echo a > " + file("app") + "; echo b >> " + file("app"), "app", "a\nb\n"},
+		{"Input from file", "echo data > " + file("in") + "; /bin/cat < " + file("in") + " > " + file("copy"), "copy", "data\n"},
+		{"Stderr to file", "/bin/sh -c 'echo out; echo err >&2' 2> " + file("err") + " > /dev/null", "err", "err\n"},
+		{"Stderr to stdout", "/bin/sh -c 'echo out; echo err >&2' > " + file("both") + " 2>&1", "both", "out\nerr\n"},
+		{"Both streams", "/bin/sh -c 'echo out; echo err >&2' &> " + file("all"), "all", "out\nerr\n"},
+		{"Builtin stderr", "kill x 2> " + file("kill"), "kill", "kill: x: arguments must be process or job IDs\n"},
+		{"Here-string", "/bin/cat <<< 'a b' > " + file("hs"), "hs", "a b\n"},
+		{"Heredoc", "/bin/cat > " + file("hd") + " <<EOF\nx $NAME\nEOF\n", "hd", "x value\n"},
+		{"Quoted heredoc", "/bin/cat > " + file("qhd") + " <<'EOF'\nx $NAME\nEOF\n", "qhd", "x $NAME\n"},
+		{"Redirect in pipeline", "echo piped | /bin/cat > " + file("pl"), "pl", "piped\n"},
+	}
+	sh := newShell(t, "")
+	sh.setVar("NAME", "value")
+	sh.exportVar("NAME")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse(tt.input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sh.runList(list)
+			got, err := os.ReadFile(file(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_jobTable_find(t *testing.T) {
+	table := newJobTable()
+	sleep := &job{cmd: "sleep 10", procs: []*process{{pid: 100}}}
+	cat := &job{cmd: "cat | wc", procs: []*process{{pid: 200}, {pid: 201}}}
+	hidden := &job{cmd: "ls", procs: []*process{{pid: 300}}}
+	for _, j := range []*job{sleep, hidden, cat} {
+		table.add(j)
+	}
+	table.setCurrent(sleep)
+	table.setCurrent(cat)
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    *job
+		wantErr bool
+	}{
+		{"Number", "%1", sleep, false},
+		{"Current", "%+", cat, false},
+		{"Current %%", "%%", cat, false},
+		{"Previous", "%-", sleep, false},
+		{"Command prefix", "%sl", sleep, false},
+		{"Pid of a later stage", "201", cat, false},
+		{"Job without number by pid", "300", hidden, false},
+		{"Unknown number", "%3", nil, true},
+		{"Unknown prefix", "%vim", nil, true},
+		{"Not a child", "12345", nil, true},
+		{"Garbage", "abc", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := table.find(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_expandWord(t *testing.T) {
+	t.Setenv("EXPORTED", "env value")
+	sh := newShell(t, "")
+	sh.vars["LOCAL"] = "a  b"
+	sh.vars["EMPTY"] = ""
+	sh.vars["FILE"] = "/tmp/archive.tar.gz"
+	sh.positional = []string{"one", "two  words"}
+	sh.lastStatus = 3
+	home := os.Getenv("HOME")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"Plain", "abc", []string{"abc"}, false},
+		{"Exported", "$EXPORTED", []string{"env", "value"}, false},
+		{"Braces", "${EXPORTED}!", []string{"env", "value!"}, false},
+		{"Double quotes keep one field", `"$LOCAL"`, []string{"a  b"}, false},
+		{"Single quotes", `'$LOCAL'`, []string{"$LOCAL"}, false},
+		{"Escaped dollar", `\$LOCAL "\$LOCAL"`, []string{"$LOCAL", "$LOCAL"}, false},
+		{"Empty unquoted disappears", "$EMPTY", nil, false},
+		{"Empty quoted stays", `"$EMPTY"`, []string{""}, false},
+		{"Unset", "$NO_SUCH_VAR", nil, false},
+		{"Status", "$?", []string{"3"}, false},
+		{"Lone dollar", "$", []string{"$"}, false},
+		{"Default", "${NO_SUCH_VAR:-x y}", []string{"x", "y"}, false},
+		{"Default for empty", `"${EMPTY:-default}"`, []string{"default"}, false},
+		{"Default only if unset", `"${EMPTY-default}"`, []string{""}, false},
+		{"Nested default", `"${NO_SUCH_VAR:-$EXPORTED}"`, []string{"env value"}, false},
+		{"Alternative", "${LOCAL:+alt}", []string{"alt"}, false},
+		{"Assign", "${ASSIGNED:=new}", []string{"new"}, false},
+		{"Length", "${#EXPORTED}", []string{"9"}, false},
+		{"Error", "${NO_SUCH_VAR:?is required}", nil, true},
+		{"Shortest prefix", "${FILE#*/}", []string{"tmp/archive.tar.gz"}, false},
+		{"Longest prefix", "${FILE##*/}", []string{"archive.tar.gz"}, false},
+		{"Shortest suffix", "${FILE%.*}", []string{"/tmp/archive.tar"}, false},
+		{"Longest suffix", "${FILE%%.*}", []string{"/tmp/archive"}, false},
+		{"Bad substitution", "${EXPORTED!}", nil, true},
+		{"Tilde", "~/bin", []string{home + "/bin"}, false},
+		{"Quoted tilde", `"~"`, []string{"~"}, false},
+		{"Tilde in assignment default", "${NO_SUCH_VAR:-~}", []string{home}, false},
+		{"Unknown user", "~no_such_user_here", []string{"~no_such_user_here"}, false},
+		{"Command substitution", "$(echo a b)", []string{"a", "b"}, false},
+		{"Quoted command substitution", `"$(echo 'a  b')"`, []string{"a  b"}, false},
+		{"Backquotes", "`echo x`y", []string{"xy"}, false},
+		{"Nested substitution", `"$(echo "$(echo in)")"`, []string{"in"}, false},
+		{"Trailing newlines are removed", `"$(printf 'a\n\n')"`, []string{"a"}, false},
+		{"Substitution is a subshell", "$(LOCAL=changed)$LOCAL", []string{"a", "b"}, false},
+		{"Positional", "$1 ${2}", []string{"one", "two", "words"}, false},
+		{"Missing positional", "$3", nil, false},
+		{"Count", "$#", []string{"2"}, false},
+		{"Quoted at keeps fields", `"$@"`, []string{"one", "two  words"}, false},
+		{"Quoted at with affixes", `"<$@>"`, []string{"<one", "two  words>"}, false},
+		{"Quoted star joins", `"$*"`, []string{"one two  words"}, false},
+		{"Exit in substitution", `"$(exit 5)$?"`, []string{"5"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse("echo "+tt.input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, args, err := sh.expand(list.items[0].pipelines[0].commands[0].(*simpleCommand))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := args[1:]; !reflect.DeepEqual(got, tt.want) && (len(got) != 0 || len(tt.want) != 0) {
+				t.Errorf("expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*.go", "task.go", true},
+		{"*.go", "task.gox", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"?", "я", true},
+		{"??", "a", false},
+		{"[abc]x", "bx", true},
+		{"[!abc]x", "bx", false},
+		{"[a-z]*", "go", true},
+		{"[a-z]*", "Go", false},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"[", "[", true},
+		{"*/*", "dir/file", true},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_glob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt", ".hidden.go", "sub/d.go", "sub/deep/e.go", ".git/f.go", "x[1].txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sh := newShell(t, dir)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"Star", "*.go", []string{"a.go", "b.go"}},
+		{"Question mark", "?.txt", []string{"c.txt"}},
+		{"Class", "[ac].*", []string{"a.go", "c.txt"}},
+		{"Hidden only with dot", ".*.go", []string{".hidden.go"}},
+		{"Directory", "*/", []string{"sub/"}},
+		{"Subdirectory", "sub/*.go", []string{"sub/d.go"}},
+		{"Recursive", "**/*.go", []string{"a.go", "b.go", "sub/d.go", "sub/deep/e.go"}},
+		{"Absolute", dir + "/*.txt", []string{dir + "/c.txt", dir + "/x[1].txt"}},
+		{"No match stays", "*.rs", []string{"*.rs"}},
+		{"Quoted pattern", `"*.go"`, []string{"*.go"}},
+		{"Escaped bracket", `x\[1\].txt`, []string{"x[1].txt"}},
+		{"Pattern from variable", "$PATTERN", []string{"a.go", "b.go"}},
+	}
+	sh.vars["PATTERN"] = "*.go"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse("echo "+tt.input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, args, err := sh.expand(list.items[0].pipelines[0].commands[0].(*simpleCommand))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args[1:], tt.want) {
+				t.Errorf("expand() = %q, want %q", args[1:], tt.want)
+			}
+		})
+	}
+}
+
+func Test_cd(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(dir, "real")
+	if err = os.MkdirAll(filepath.Join(real, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(real, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("PWD", wd)
+	t.Setenv("CDPATH", "")
+	sh := newShell(t, "")
+	sh.unsetVar("OLDPWD")
+
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantPwd string
+		wantOut string
+		wantErr bool
+	}{
+		{"No previous directory", "-", wd, wd, "", true},
+		{"Absolute", "/", "/", "/", "", false},
+		{"Back", "-", wd, wd, wd + "\n", false},
+		{"Home", "~", dir, dir, "", false},
+		{"Missing", "no/such/dir", dir, dir, "", true},
+		{"Back again", "-", wd, wd, wd + "\n", false},
+		{"No arguments", "", dir, dir, "", false},
+		{"Through symlink", "link/sub", filepath.Join(real, "sub"), filepath.Join(dir, "link", "sub"), "", false},
+		{"Logical parent", "..", real, filepath.Join(dir, "link"), "", false},
+		{"Physical parent", "-P ..", dir, dir, "", false},
+		{"CDPATH", "sub", filepath.Join(real, "sub"), filepath.Join(dir, "link", "sub"), filepath.Join(dir, "link", "sub") + "\n", false},
+		{"Too many arguments", "a b", filepath.Join(real, "sub"), filepath.Join(dir, "link", "sub"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "CDPATH" {
+				sh.setVar("CDPATH", ":"+filepath.Join(dir, "link"))
+			}
+			list, err := parse("cd "+tt.args, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, args, err := sh.expand(list.items[0].pipelines[0].commands[0].(*simpleCommand))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if status := sh.cdCommand(args[1:], stdio{out: &out, err: io.Discard}); (status != 0) != tt.wantErr {
+				t.Fatalf("cd() = %v, wantErr %v", status, tt.wantErr)
+			}
+			if got, _ := sh.physicalDir(); got != tt.want {
+				t.Errorf("cwd = %v, want %v", got, tt.want)
+			}
+			if got := sh.logicalDir(); got != tt.wantPwd {
+				t.Errorf("logicalDir() = %v, want %v", got, tt.wantPwd)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("cd() printed %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+
+	out := &bytes.Buffer{}
+	sh.pwdCommand([]string{"-P"}, stdio{out: out})
+	sh.pwdCommand(nil, stdio{out: out})
+	if want := filepath.Join(real, "sub") + "\n" + filepath.Join(dir, "link", "sub") + "\n"; out.String() != want {
+		t.Errorf("pwd printed %q, want %q", out.String(), want)
+	}
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("cd changed the process directory to %v", got)
+	}
+}
+
+func Test_expandPrompt(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PWD", wd)
+	t.Setenv("HOME", filepath.Dir(wd))
+	now := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC)
+	sh := newShell(t, "")
+	sh.lastStatus = 3
+
+	tests := []struct {
+		name string
+		ps1  string
+		want string
+	}{
+		{"Plain", "$ ", "$ "},
+		{"Cwd", `\w>`, "~/" + filepath.Base(wd) + ">"},
+		{"Basename", `\W`, filepath.Base(wd)},
+		{"Status", `[\?]`, "[3]"},
+		{"Time", `\t \A \T \@`, "14:07:09 14:07 02:07:09 02:07 PM"},
+		{"Date", `\d`, "Tue Mar 05"},
+		{"Escapes", `\\\n\q`, "\\\n\\q"},
+		{"Non-printing", `\[\e[1m\]>`, "\x01\x1b[1m\x02>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sh.expandPrompt(tt.ps1, now); got != tt.want {
+				t.Errorf("expandPrompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if text, width := visiblePrompt("\x01\x1b[1m\x02> "); text != "\x1b[1m> " || width != 2 {
+		t.Errorf("visiblePrompt() = %q, %v, want %q, 2", text, width, "\x1b[1m> ")
+	}
+}
+
+func Test_expandAlias(t *testing.T) {
+	aliases := map[string]string{"ll": "ls -l", "ls": "ls -F", "both": "ll |"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Simple", "ll /tmp", "ls -F -l /tmp;"},
+		{"Self reference", "ls", "ls -F;"},
+		{"Only command position", "echo ll", "echo ll;"},
+		{"Quoted", "'ll'", "'ll';"},
+		{"Pipeline", "both wc", "ls -F -l | wc;"},
+		{"After operator", "true && ll; ll", "true && ls -F -l; ls -F -l;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parse(tt.input, aliases)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := list.String(); got != tt.want {
+				t.Errorf("parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_completions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"alpha.txt", "alpine/x", "beta file", ".hidden"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sh := newShell(t, dir)
+	sh.setVar("PATH", dir+"/alpine")
+
+	tests := []struct {
+		name      string
+		line      string
+		wantStart int
+		want      []string
+	}{
+		{"Builtin", "ec", 0, []string{"echo"}},
+		{"Executable from PATH", "x", 0, []string{"x"}},
+		{"Command after pipe", "ls | ec", 5, []string{"echo"}},
+		{"File argument", "cat al", 4, []string{"alpha.txt", "alpine/"}},
+		{"Escaped space", `cat beta\ f`, 4, []string{"beta file"}},
+		{"Hidden only with dot", "cat .h", 4, []string{".hidden"}},
+		{"Inside directory", "cat alpine/", 4, []string{"alpine/x"}},
+		{"Path as command", "./al", 0, []string{"./alpha.txt", "./alpine/"}},
+		{"Redirect target", "echo x >al", 8, []string{"alpha.txt", "alpine/"}},
+		{"No match", "cat zz", 4, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := []rune(tt.line)
+			start, got := sh.completions(line, len(line))
+			if start != tt.wantStart {
+				t.Errorf("start = %v, want %v", start, tt.wantStart)
+			}
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("completions() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_history(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	sh := newShell(t, "")
+	sh.setVar("HISTFILE", path)
+
+	h := sh.loadHistory()
+	for _, line := range []string{"ls", "ls", "  ", "pwd", "ls"} {
+		h.add(line)
+	}
+	want := []string{"ls", "pwd", "ls"}
+	if !reflect.DeepEqual(h.entries, want) {
+		t.Errorf("entries = %q, want %q", h.entries, want)
+	}
+	if got := sh.loadHistory().entries; !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded entries = %q, want %q", got, want)
+	}
+}
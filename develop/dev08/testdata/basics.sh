@@ -0,0 +1,9 @@
+# переменные, кавычки, конвейеры и подстановки
+name=world
+echo "hello, $name" 'and $name'
+echo one two three | tr ' ' '\n' | sort -r
+count=$(printf 'a\nb\nc\n' | wc -l)
+echo "lines: $count"
+export GREETING=hi
+sh -c 'echo "child sees $GREETING"'
+echo ${missing:-default} $(expr 2 + 3 \* 4)
@@ -0,0 +1,21 @@
+# составные команды и функции
+greet() {
+	local who=$1
+	echo "hi $who"
+	return 3
+}
+greet bob
+echo "status $?"
+for i in 1 2 3 4; do
+	if [ $i -eq 2 ]; then continue; fi
+	if [ $i -eq 4 ]; then break; fi
+	echo "i=$i"
+done
+n=0
+while [ $n -lt 3 ]; do n=$(expr $n + 1); done
+echo "n=$n"
+case "file.txt" in
+*.go) echo go ;;
+*.txt) echo text ;;
+esac
+echo "who=${who:-unset}"
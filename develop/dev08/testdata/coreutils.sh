@@ -0,0 +1,25 @@
+# cat, sort, grep и cut из dev03, dev05 и dev06 выполняются в самом шелле:
+# с пустым PATH внешних программ нет
+PATH=
+type cat sort grep cut wget
+cat > fields.txt <<END
+3	xray	c
+1	box	a
+2	yak	b
+4	Xmas	d
+END
+cat fields.txt | grep -i x | sort -n | cut -f=2
+cat > data.txt <<END
+x 3 b
+foo 1 X
+bar 2 x
+baz 4 y
+END
+grep -i x < data.txt | sort -n -k=2 | cut -d=" " -f=0,1
+grep -c -v y < data.txt
+grep nothing < data.txt || echo "grep status $?"
+sort -r data.txt
+sort -c data.txt || echo "sort status $?"
+echo stdin | cat - missing.txt || echo "cat status $?"
+set +o coreutils
+cat data.txt || echo "cat status $?"
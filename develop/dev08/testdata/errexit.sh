@@ -0,0 +1,7 @@
+# set -e завершает скрипт с кодом неудачной команды
+set -e
+echo before
+if false; then echo never; fi
+false || echo "or is fine"
+sh -c 'exit 7'
+echo after
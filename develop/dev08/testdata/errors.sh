@@ -0,0 +1,9 @@
+# ошибки пишутся в stderr, код - последней команды
+nosuchcommand
+echo "status $?"
+cd /no/such/dir
+kill -l 130
+alias ll='echo aliased'
+ll
+unalias ll
+type ll
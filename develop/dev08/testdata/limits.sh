@@ -0,0 +1,18 @@
+# time, timeout и ulimit
+timeout 0.2 sleep 5; echo "timeout $?"
+timeout --preserve-status 0.2 sleep 5; echo "preserve $?"
+timeout -s KILL 0.2 sleep 5; echo "kill $?"
+timeout -k 0.2 0.2 sh -c 'trap "" TERM; sleep 5'; echo "kill after $?"
+timeout 0.2 sh -c 'sleep 5 & wait'; echo "group $?"
+timeout 5 true; echo "in time $?"
+f() { sleep 5; }
+timeout 0.2 f; echo "function $?"
+timeout x sleep 1; echo "bad interval $?"
+timeout 1 nosuchcmd; echo "not found $?"
+timeout 1; echo "usage $?"
+{ time -p true; } 2>/dev/null; echo "time $?"
+{ time ! true | cat; } 2>/dev/null; echo "time pipeline $?"
+ulimit -n 64
+sh -c 'ulimit -n'
+(ulimit -n 32; sh -c 'ulimit -n')
+ulimit -n
@@ -0,0 +1,13 @@
+# перенаправления и каталоги
+mkdir -p sub/dir
+cd sub
+pwd
+echo first > out.txt
+echo second >> out.txt
+cat < out.txt
+cat missing.txt 2> err.txt || echo "cat failed"
+wc -l < err.txt
+cd -
+cat <<END
+heredoc in $(pwd)
+END
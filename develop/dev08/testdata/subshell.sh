@@ -0,0 +1,17 @@
+# подоболочки, группы, ! и && ||
+mkdir inner
+(cd inner; pwd; name=inside; export LEAK=1; exit 3)
+echo "status $? in $(pwd) name=${name:-unset} leak=${LEAK:-unset}"
+(echo b; echo a) | sort
+{ echo out; echo err >&2; } 2> err.txt > out.txt
+cat out.txt err.txt
+! false && echo "negated false"
+! true || echo "negated true"
+false && echo never || echo "or after and"
+(false) | cat; echo "pipeline status $?"
+( ( echo nested ) )
+set -e
+! true
+echo "set -e ignores !"
+(exit 5)
+echo never
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// cpuTimer копит процессорное время процессов конвейера с time. Время процесса
+// берется из wait4 при его завершении и включает время его завершившихся потомков
+type cpuTimer struct {
+	start     time.Time
+	user, sys time.Duration
+}
+
+// measure начинает замер конвейера с time в шелле. Возвращенная функция печатает
+// в stderr прошедшее время и процессорное время процессов, запущенных за это время
+func (sh *Shell) measure(posix bool) func() {
+	timer := &cpuTimer{start: time.Now()}
+	sh.timers = append(sh.timers, timer)
+	return func() {
+		sh.timers = sh.timers[:len(sh.timers)-1]
+		sh.report(timer, posix)
+	}
+}
+
+// add учитывает время завершившегося процесса, вызывается под mu таблицы заданий
+func (c *cpuTimer) add(usage *syscall.Rusage) {
+	c.user += time.Duration(usage.Utime.Nano())
+	c.sys += time.Duration(usage.Stime.Nano())
+}
+
+// report печатает замер c в stderr шелла
+func (sh *Shell) report(c *cpuTimer, posix bool) {
+	sh.jobs.mu.Lock()
+	user, sys := c.user, c.sys
+	sh.jobs.mu.Unlock()
+	fmt.Fprint(sh.stderr, formatTimes(time.Since(c.start), user, sys, posix))
+}
+
+// formatTimes - отчет time: как в bash, а с -p - в формате POSIX
+func formatTimes(real, user, sys time.Duration, posix bool) string {
+	if posix {
+		return fmt.Sprintf("real %.2f\nuser %.2f\nsys %.2f\n", real.Seconds(), user.Seconds(), sys.Seconds())
+	}
+	return fmt.Sprintf("\nreal\t%s\nuser\t%s\nsys\t%s\n", minutes(real), minutes(user), minutes(sys))
+}
+
+// minutes записывает длительность как 1m2.345s
+func minutes(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	m := d / time.Minute
+	return fmt.Sprintf("%dm%.3fs", m, (d - m*time.Minute).Seconds())
+}
+
+// deadline - срок процесса, запущенного через timeout
+type deadline struct {
+	sig            syscall.Signal
+	killAfter      time.Duration
+	preserveStatus bool
+	timer          *time.Timer
+	expired        bool
+}
+
+// timeout [-s SIG] [-k DURATION] [--preserve-status] DURATION command [args...] - запускает
+// команду и, если она не завершилась за DURATION, посылает ее группе процессов SIG
+// (по умолчанию TERM), а если задан -k, то через DURATION еще и KILL. Код возврата
+// тогда 124. При управлении заданиями группа - все задание, иначе команда получает
+// свою группу. Функции и встроенные команды выполняются дочерним шеллом
+func (sh *Shell) timeout(j *job, fg bool, args []string, fds *fdTable, env []string) {
+	fail := func(format string, a ...any) {
+		fmt.Fprintf(fds.stdio().err, "timeout: "+format+"\n", a...)
+		fds.close()
+		sh.jobs.addProcess(j, &process{state: jobDone, status: 125})
+	}
+	d := &deadline{sig: syscall.SIGTERM}
+options:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := args[0]
+		args = args[1:]
+		switch opt {
+		case "--":
+			break options
+		case "--preserve-status":
+			d.preserveStatus = true
+		case "-s", "-k":
+			if len(args) == 0 {
+				fail("%s: option requires an argument", opt)
+				return
+			}
+			var err error
+			if opt == "-s" {
+				d.sig, err = parseSignal(args[0])
+			} else {
+				d.killAfter, err = parseInterval(args[0])
+			}
+			if err != nil {
+				fail("%v", err)
+				return
+			}
+			args = args[1:]
+		default:
+			fail("%s: invalid option", opt)
+			return
+		}
+	}
+	if len(args) < 2 {
+		fail("usage: timeout [-s signal] [-k duration] [--preserve-status] duration command [args...]")
+		return
+	}
+	interval, err := parseInterval(args[0])
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+
+	name, argv := args[1], args[2:]
+	_, isFunction := sh.functions[name]
+	if _, isBuiltin := sh.builtinNames()[name]; isFunction || isBuiltin {
+		argv, env = sh.childShell(&simpleCommand{}, args[1:], env)
+		name = shellPath()
+	}
+	p := sh.spawn(j, fg, name, argv, fds, env, true)
+	if p == nil || interval == 0 {
+		return
+	}
+	pgid := j.pgid
+	if pgid == 0 {
+		pgid = p.pid
+	}
+	d.start(sh.jobs, p, pgid, interval)
+}
+
+// parseInterval разбирает интервал timeout: число секунд, возможно дробное,
+// с необязательным суффиксом s, m, h или d. 0 - без ограничения
+func parseInterval(s string) (time.Duration, error) {
+	number, unit := s, time.Second
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 's':
+			number = s[:n-1]
+		case 'm':
+			number, unit = s[:n-1], time.Minute
+		case 'h':
+			number, unit = s[:n-1], time.Hour
+		case 'd':
+			number, unit = s[:n-1], 24*time.Hour
+		}
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || f < 0 || math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid time interval '%s'", s)
+	}
+	if f*float64(unit) >= math.MaxInt64 {
+		return math.MaxInt64, nil
+	}
+	return time.Duration(f * float64(unit)), nil
+}
+
+// start через interval посылает сигнал группе pgid, а через killAfter после
+// него - SIGKILL. Состояние процесса меняет reap таблицы jobs, поэтому таймеры
+// проверяют его под jobs.mu
+func (d *deadline) start(jobs *jobTable, p *process, pgid int, interval time.Duration) {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	if p.state == jobDone {
+		return
+	}
+	p.deadline = d
+	d.timer = time.AfterFunc(interval, func() {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if p.state == jobDone {
+			return
+		}
+		d.expired = true
+		syscall.Kill(-pgid, d.sig)
+		if d.sig != syscall.SIGKILL && d.sig != syscall.SIGCONT {
+			// остановленный процесс не обработает сигнал, пока стоит
+			syscall.Kill(-pgid, syscall.SIGCONT)
+		}
+		if d.killAfter > 0 {
+			d.timer = time.AfterFunc(d.killAfter, func() {
+				jobs.mu.Lock()
+				defer jobs.mu.Unlock()
+				if p.state != jobDone {
+					syscall.Kill(-pgid, syscall.SIGKILL)
+				}
+			})
+		}
+	})
+}
+
+// finish останавливает таймер завершившегося процесса и возвращает его код возврата:
+// 124, если срок истек
+func (d *deadline) finish(status int) int {
+	if d == nil {
+		return status
+	}
+	d.timer.Stop()
+	if d.expired && !d.preserveStatus {
+		return 124
+	}
+	return status
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// номера ресурсов Linux, которых нет в пакете syscall
+const (
+	rlimitRSS     = 5
+	rlimitNPROC   = 6
+	rlimitMEMLOCK = 8
+)
+
+const rlimInfinity = math.MaxUint64
+
+// resource - ресурс, который ограничивает ulimit. unit - во сколько байт (или
+// других единиц ядра) переводится единица значения ulimit
+type resource struct {
+	option byte
+	id     int
+	name   string
+	units  string
+	unit   uint64
+}
+
+// resources - ресурсы в порядке вывода ulimit -a
+var resources = []resource{
+	{'c', syscall.RLIMIT_CORE, "core file size", "blocks", 1024},
+	{'d', syscall.RLIMIT_DATA, "data seg size", "kbytes", 1024},
+	{'f', syscall.RLIMIT_FSIZE, "file size", "blocks", 1024},
+	{'l', rlimitMEMLOCK, "max locked memory", "kbytes", 1024},
+	{'m', rlimitRSS, "max memory size", "kbytes", 1024},
+	{'n', syscall.RLIMIT_NOFILE, "open files", "", 1},
+	{'s', syscall.RLIMIT_STACK, "stack size", "kbytes", 1024},
+	{'t', syscall.RLIMIT_CPU, "cpu time", "seconds", 1},
+	{'u', rlimitNPROC, "max user processes", "", 1},
+	{'v', syscall.RLIMIT_AS, "virtual memory", "kbytes", 1024},
+}
+
+// limitsVar передает ограничения процессу-посреднику. Go не дает выполнить setrlimit
+// между fork и exec, поэтому при заданных ограничениях вместо команды запускается
+// сам шелл: он устанавливает их себе и заменяет себя командой через exec.
+// Значение - "ресурс:мягкое:жесткое,...;путь к команде"
+const limitsVar = "DEV08_RLIMITS"
+
+// currentLimit - ограничение, с которым запустится следующий процесс
+func (sh *Shell) currentLimit(id int) (syscall.Rlimit, error) {
+	if lim, ok := sh.limits[id]; ok {
+		return lim, nil
+	}
+	var lim syscall.Rlimit
+	err := syscall.Getrlimit(id, &lim)
+	return lim, err
+}
+
+// withLimits возвращает путь и окружение, с которыми команда path запустится
+// через посредника с ограничениями ulimit
+func (sh *Shell) withLimits(path string, env []string) (string, []string) {
+	if len(sh.limits) == 0 {
+		return path, env
+	}
+	fields := make([]string, 0, len(sh.limits))
+	for id, lim := range sh.limits {
+		fields = append(fields, fmt.Sprintf("%d:%d:%d", id, lim.Cur, lim.Max))
+	}
+	return shellPath(), append(env[:len(env):len(env)], limitsVar+"="+strings.Join(fields, ",")+";"+path)
+}
+
+// execWithLimits выполняет работу посредника, если шелл запущен им, и не возвращается
+func execWithLimits() {
+	value, ok := os.LookupEnv(limitsVar)
+	if !ok {
+		return
+	}
+	os.Unsetenv(limitsVar)
+	spec, path, _ := strings.Cut(value, ";")
+	for _, field := range strings.Split(spec, ",") {
+		var id int
+		var lim syscall.Rlimit
+		if _, err := fmt.Sscanf(field, "%d:%d:%d", &id, &lim.Cur, &lim.Max); err != nil {
+			fmt.Fprintf(os.Stderr, "ulimit: %s: %v\n", field, err)
+			os.Exit(126)
+		}
+		if err := syscall.Setrlimit(id, &lim); err != nil {
+			fmt.Fprintf(os.Stderr, "ulimit: %s: %v\n", field, err)
+			os.Exit(126)
+		}
+	}
+	err := syscall.Exec(path, os.Args, os.Environ())
+	fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+	os.Exit(126)
+}
+
+// ulimit [-SH] [-a | -cdflmnstuv [limit]] - показать или изменить ограничения ресурсов
+// для запускаемых процессов. Без -S и -H меняются оба ограничения, а печатается мягкое.
+// limit - число в единицах ресурса, unlimited, soft или hard
+func (sh *Shell) ulimit(args []string, std stdio) int {
+	soft, hard, all := false, false, false
+	var selected []resource
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, c := range []byte(args[0][1:]) {
+			switch c {
+			case 'S':
+				soft = true
+			case 'H':
+				hard = true
+			case 'a':
+				all = true
+			default:
+				r, ok := findResource(c)
+				if !ok {
+					fmt.Fprintf(std.err, "ulimit: -%c: invalid option\n", c)
+					fmt.Fprintln(std.err, "ulimit: usage: ulimit [-SH] [-a | -cdflmnstuv [limit]]")
+					return 2
+				}
+				selected = append(selected, r)
+			}
+		}
+		args = args[1:]
+	}
+	if len(args) > 1 || (all && len(args) > 0) {
+		fmt.Fprintln(std.err, "ulimit: too many arguments")
+		return 2
+	}
+	if all {
+		selected = resources
+	}
+	if len(selected) == 0 {
+		// без ресурса ulimit работает с размером файла, как в sh
+		selected = []resource{resources[2]}
+	}
+	if !soft && !hard {
+		soft, hard = true, len(args) > 0
+	}
+
+	status := 0
+	for _, r := range selected {
+		lim, err := sh.currentLimit(r.id)
+		if err != nil {
+			fmt.Fprintf(std.err, "ulimit: %s: cannot get limit: %v\n", r.name, err)
+			status = 1
+			continue
+		}
+		if len(args) == 0 {
+			value := lim.Max
+			if soft {
+				value = lim.Cur
+			}
+			if len(selected) > 1 {
+				units := "(-" + string(r.option) + ") "
+				if r.units != "" {
+					units = "(" + r.units + ", -" + string(r.option) + ") "
+				}
+				fmt.Fprintf(std.out, "%-20s %20s", r.name, units)
+			}
+			fmt.Fprintln(std.out, formatLimit(value, r.unit))
+			continue
+		}
+		if err = sh.setLimit(r, lim, args[0], soft, hard); err != nil {
+			fmt.Fprintf(std.err, "ulimit: %s: %v\n", r.name, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func findResource(option byte) (resource, bool) {
+	for _, r := range resources {
+		if r.option == option {
+			return r, true
+		}
+	}
+	return resource{}, false
+}
+
+// setLimit проверяет новое ограничение, как это сделал бы setrlimit(2): мягкое не больше
+// жесткого, а жесткое без прав root только уменьшается
+func (sh *Shell) setLimit(r resource, lim syscall.Rlimit, spec string, soft, hard bool) error {
+	var value uint64
+	switch spec {
+	case "unlimited":
+		value = rlimInfinity
+	case "soft":
+		value = lim.Cur
+	case "hard":
+		value = lim.Max
+	default:
+		n, err := strconv.ParseUint(spec, 10, 64)
+		if err != nil || n > rlimInfinity/r.unit {
+			return fmt.Errorf("%s: invalid number", spec)
+		}
+		value = n * r.unit
+	}
+	next := lim
+	if soft {
+		next.Cur = value
+	}
+	if hard {
+		next.Max = value
+	}
+	if next.Cur > next.Max {
+		return fmt.Errorf("cannot modify limit: %v", syscall.EINVAL)
+	}
+	if next.Max > lim.Max && os.Geteuid() != 0 {
+		return fmt.Errorf("cannot modify limit: %v", syscall.EPERM)
+	}
+	sh.limits[r.id] = next
+	return nil
+}
+
+func formatLimit(value, unit uint64) string {
+	if value == rlimInfinity {
+		return "unlimited"
+	}
+	return strconv.FormatUint(value/unit, 10)
+}
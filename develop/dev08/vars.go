@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lookupVar возвращает значение переменной или специального параметра
+func (sh *Shell) lookupVar(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(sh.lastStatus), true
+	case "$":
+		return strconv.Itoa(sh.pid), true
+	case "!":
+		if sh.lastBackgroundPid == 0 {
+			return "", false
+		}
+		return strconv.Itoa(sh.lastBackgroundPid), true
+	case "0":
+		return sh.name, true
+	case "#":
+		return strconv.Itoa(len(sh.positional)), true
+	case "@", "*":
+		return strings.Join(sh.positional, " "), true
+	case "-":
+		flags := ""
+		if sh.options.errexit {
+			flags += "e"
+		}
+		if sh.options.xtrace {
+			flags += "x"
+		}
+		return flags, true
+	}
+	if isDigits(name) {
+		n, _ := strconv.Atoi(name)
+		if n > len(sh.positional) {
+			return "", false
+		}
+		return sh.positional[n-1], true
+	}
+	if value, ok := sh.vars[name]; ok {
+		return value, true
+	}
+	value, ok := sh.env[name]
+	return value, ok
+}
+
+// setVar присваивает значение. Экспортированная переменная остается в окружении
+func (sh *Shell) setVar(name, value string) {
+	if _, exported := sh.env[name]; exported || sh.exportPending[name] {
+		delete(sh.exportPending, name)
+		sh.env[name] = value
+		return
+	}
+	sh.vars[name] = value
+}
+
+func (sh *Shell) exportVar(name string) {
+	if value, ok := sh.vars[name]; ok {
+		delete(sh.vars, name)
+		sh.env[name] = value
+	} else if _, ok = sh.env[name]; !ok {
+		sh.exportPending[name] = true
+	}
+}
+
+func (sh *Shell) unsetVar(name string) {
+	delete(sh.vars, name)
+	delete(sh.exportPending, name)
+	delete(sh.env, name)
+}
+
+// environ - экспортированные переменные в виде "имя=значение", по порядку имен
+func (sh *Shell) environ() []string {
+	env := make([]string, 0, len(sh.env))
+	for name, value := range sh.env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+func isName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, c := range s {
+		if !isNameChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameChar(c rune) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// childEnv - окружение для запускаемой команды с присваиваниями NAME=value перед ней
+func (sh *Shell) childEnv(assigns []assignment) []string {
+	env := sh.environ()
+	for _, a := range assigns {
+		prefix := a.name + "="
+		env = slices.DeleteFunc(env, func(kv string) bool { return strings.HasPrefix(kv, prefix) })
+		env = append(env, prefix+a.value)
+	}
+	return env
+}
+
+// export [NAME[=value] ...] - без аргументов печатает экспортированные переменные
+func (sh *Shell) export(args []string, std stdio) int {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-p") {
+		for _, kv := range sh.environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			fmt.Fprintf(std.out, "export %s=%s\n", name, strconv.Quote(value))
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			fmt.Fprintf(std.err, "export: `%s': not a valid identifier\n", arg)
+			status = 1
+			continue
+		}
+		sh.exportVar(name)
+		if hasValue {
+			sh.setVar(name, value)
+		}
+	}
+	return status
+}
+
+// read [-r] [-p prompt] [NAME ...] читает строку из stdin и делит ее по $IFS: каждой
+// переменной по полю, последней - остаток строки. Без имен строка попадает в $REPLY.
+// Ввод читается по байту, чтобы следующая команда получила остаток stdin
+func (sh *Shell) read(args []string, std stdio) int {
+	raw := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		switch args[0] {
+		case "-r":
+			raw = true
+		case "-p":
+			if len(args) < 2 {
+				fmt.Fprintln(std.err, "read: -p: option requires an argument")
+				return 2
+			}
+			fmt.Fprint(std.err, args[1])
+			args = args[1:]
+		default:
+			fmt.Fprintf(std.err, "read: %s: invalid option\n", args[0])
+			return 2
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		args = []string{"REPLY"}
+	}
+
+	line := &strings.Builder{}
+	status := 0
+	b := make([]byte, 1)
+	for {
+		if n, err := std.in.Read(b); n == 0 || err != nil {
+			status = 1
+			break
+		}
+		if b[0] == '\n' {
+			break
+		}
+		if b[0] == '\\' && !raw {
+			if n, err := std.in.Read(b); n == 0 || err != nil {
+				status = 1
+				break
+			}
+			if b[0] == '\n' {
+				continue
+			}
+		}
+		line.WriteByte(b[0])
+	}
+	if status != 0 && line.Len() == 0 {
+		for _, name := range args {
+			sh.setVar(name, "")
+		}
+		return status
+	}
+
+	ifs, ok := sh.lookupVar("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	isIFS := func(c rune) bool { return strings.ContainsRune(ifs, c) }
+	rest := strings.TrimLeftFunc(line.String(), isIFS)
+	for i, name := range args {
+		if !isName(name) {
+			fmt.Fprintf(std.err, "read: `%s': not a valid identifier\n", name)
+			return 2
+		}
+		if i == len(args)-1 {
+			sh.setVar(name, strings.TrimRightFunc(rest, isIFS))
+			break
+		}
+		end := strings.IndexFunc(rest, isIFS)
+		if end < 0 {
+			end = len(rest)
+		}
+		sh.setVar(name, rest[:end])
+		rest = strings.TrimLeftFunc(rest[end:], isIFS)
+	}
+	return status
+}
+
+// unset [-v] NAME ...
+func (sh *Shell) unset(args []string, std stdio) int {
+	if len(args) > 0 && args[0] == "-v" {
+		args = args[1:]
+	}
+	status := 0
+	for _, name := range args {
+		if !isName(name) {
+			fmt.Fprintf(std.err, "unset: `%s': not a valid identifier\n", name)
+			status = 1
+			continue
+		}
+		sh.unsetVar(name)
+	}
+	return status
+}
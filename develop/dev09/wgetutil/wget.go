@@ -0,0 +1,174 @@
+// Package wgetutil - утилита wget из dev09 с выводом через io.Writer
+package wgetutil
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Run выполняет wget с аргументами командной строки: wget [-d depth] url
+func Run(args []string, stdout, stderr io.Writer) int {
+	return RunIn("", args, stdout, stderr)
+}
+
+// RunIn - Run, который скачивает страницы в каталог dir, пустой dir - текущий каталог
+func RunIn(dir string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("wget", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	depth := fs.Int("d", 1, "Depth for recursive download")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "No url specified")
+		return 1
+	}
+	if err := wget(dir, fs.Arg(0), *depth, stdout, stderr); err != nil {
+		fmt.Fprintln(stderr, "wget:", err)
+		return 1
+	}
+	return 0
+}
+
+// downloader - состояние одного запуска wget: каталог для страниц, куда писать ошибки
+// и была ли ошибка записи
+type downloader struct {
+	dir    string
+	stderr io.Writer
+	err    error
+}
+
+// скачать страницу
+func (d *downloader) getUrlPage(uri string) []byte {
+	resp, err := http.Get(uri)
+	if err != nil {
+		fmt.Fprintln(d.stderr, "http err: ", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(d.stderr, "error status: %s\n", resp.Status)
+		return nil
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(d.stderr, "error reading page: %v\n", err)
+		return nil
+	}
+	return b
+}
+
+// Wget скачивает страницу url в текущий каталог, а с depth больше 1 - и страницы
+// по ссылкам с нее, заменяя ссылки на пути к скачанным файлам
+func Wget(url string, depth int, stdout, stderr io.Writer) error {
+	return wget("", url, depth, stdout, stderr)
+}
+
+func wget(dir, url string, depth int, stdout, stderr io.Writer) error {
+	fmt.Fprintln(stdout, "downloading "+url)
+	if depth < 1 {
+		return errors.New("wrong depth")
+	}
+	d := &downloader{dir: dir, stderr: stderr}
+	trimmed := strings.TrimLeft(url, `https://`)
+	splitted := strings.Split(trimmed, "/")
+	baseUrl := splitted[0]
+	d.wgetRec(depth, url, baseUrl, "", 0)
+	return d.err
+}
+
+// рекурсивный wget
+func (d *downloader) wgetRec(depth int, url, baseUrl, oldPath string, recDepth int) {
+	if recDepth == depth {
+		return
+	}
+	page := d.getUrlPage(url)
+	if page != nil {
+		links := parseLinks(page, baseUrl)
+		//находим ссылки, проходим по ним и меняем на соответствующие директории
+		for _, val := range links {
+			byteVal := []byte(val)
+			newLink := "./" + oldPath + d.linkToFilePath(val)
+			page = bytes.ReplaceAll(page, byteVal, []byte(newLink))
+		}
+		//записываем страницу
+		err := d.writeToFile(page, oldPath+d.linkToFilePath(url)+"/")
+		if err != nil {
+			fmt.Fprintln(d.stderr, err)
+			d.err = err
+			return
+		}
+		for _, v := range links {
+			link := `https://` + d.linkToFilePath(v)
+			splitted := strings.Split(v, "/")
+			baseUrl = splitted[0]
+			d.wgetRec(depth, link, baseUrl, d.linkToFilePath(url)+`/`, recDepth+1)
+		}
+	}
+}
+
+func (d *downloader) writeToFile(data []byte, dirname string) error {
+	root := "."
+	if d.dir != "" {
+		root = strings.TrimSuffix(d.dir, "/")
+	}
+	if err := os.MkdirAll(root+"/"+dirname, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(root + "/" + dirname + "index.html")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+func parseLinks(data []byte, baseUrl string) []string {
+	links := make([]string, 0)
+	body := bytes.NewReader(data)
+	tokenizer := html.NewTokenizer(body)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if "a" == token.Data {
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						if strings.HasPrefix(attr.Val, "/") {
+							attr.Val = baseUrl + attr.Val
+						}
+						links = append(links, attr.Val)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (d *downloader) linkToFilePath(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		fmt.Fprintln(d.stderr, "problem while parsing url")
+		return ""
+	}
+	p := u.Hostname() + "/" + u.EscapedPath()
+	fullPath := strings.Split(p, "/")
+	if len(fullPath[len(fullPath)-1]) == 0 {
+		fullPath = fullPath[:len(fullPath)-1]
+	}
+	return path.Join(fullPath...)
+}
@@ -0,0 +1,60 @@
+package wgetutil
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_RunIn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><body>page</body></html>")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+		wantFile   string
+	}{
+		{"Page", []string{server.URL}, "downloading " + server.URL + "\n", "", 0, "127.0.0.1/index.html"},
+		{"Missing page", []string{server.URL + "/none"}, "downloading " + server.URL + "/none\n", "error status: 404 Not Found\n", 0, ""},
+		{"No url", nil, "", "No url specified\n", 1, ""},
+		{"Wrong depth", []string{"-d", "0", server.URL}, "downloading " + server.URL + "\n", "wget: wrong depth\n", 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var out, errOut bytes.Buffer
+			if status := RunIn(dir, tt.args, &out, &errOut); status != tt.wantStatus {
+				t.Errorf("RunIn() = %v, want %v", status, tt.wantStatus)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("RunIn() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if errOut.String() != tt.wantErr {
+				t.Errorf("RunIn() stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+			if tt.wantFile == "" {
+				return
+			}
+			if _, err := os.Stat(tt.wantFile); err == nil {
+				t.Errorf("RunIn() wrote %s to the current directory", tt.wantFile)
+			}
+			if data, err := os.ReadFile(filepath.Join(dir, tt.wantFile)); err != nil || string(data) != "<html><body>page</body></html>" {
+				t.Errorf("%s = %q, %v", tt.wantFile, data, err)
+			}
+		})
+	}
+}
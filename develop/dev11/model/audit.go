@@ -0,0 +1,104 @@
+package model
+
+import (
+	"reflect"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	ActionCreate  AuditAction = "create"
+	ActionUpdate  AuditAction = "update"
+	ActionDelete  AuditAction = "delete"
+	ActionRespond AuditAction = "respond"
+	ActionShare   AuditAction = "share"
+	ActionUnshare AuditAction = "unshare"
+)
+
+// AuditRecord - запись журнала изменений: кто, когда и как изменил событие
+// или доступ к календарю. У записей о доступе EventId нет, а Share - доступ
+// после share или отозванный unshare
+type AuditRecord struct {
+	Id      uint          `json:"id"`
+	EventId uint          `json:"event_id,omitempty"`
+	UserId  uint          `json:"user_id"`
+	Action  AuditAction   `json:"action"`
+	Time    time.Time     `json:"time"`
+	Before  *Event        `json:"before,omitempty"`
+	After   *Event        `json:"after,omitempty"`
+	Share   *Share        `json:"share,omitempty"`
+	Changes []FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// Diff возвращает поля события, отличающиеся в before и after. nil означает,
+// что события нет: до создания или после удаления
+func Diff(before, after *Event) []FieldChange {
+	var b, a Event
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+
+	fields := []struct {
+		name          string
+		before, after any
+	}{
+		{"date", b.Date, a.Date},
+		{"name", b.Name, a.Name},
+		{"description", b.Description, a.Description},
+		{"user_id", b.CreatorId, a.CreatorId},
+		{"duration", b.Duration, a.Duration},
+		{"attendees", b.Attendees, a.Attendees},
+	}
+	changes := make([]FieldChange, 0)
+	for _, f := range fields {
+		if isEqual(f.before, f.after) {
+			continue
+		}
+		change := FieldChange{Field: f.name}
+		if before != nil {
+			change.Before = f.before
+		}
+		if after != nil {
+			change.After = f.after
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// ShareDiff возвращает изменение роли пользователя в календаре. nil означает,
+// что доступа нет: до share или после unshare
+func ShareDiff(before, after *Share) []FieldChange {
+	change := FieldChange{Field: "role"}
+	if before != nil {
+		change.Before = before.Role
+	}
+	if after != nil {
+		change.After = after.Role
+	}
+	if change.Before == change.After {
+		return make([]FieldChange, 0)
+	}
+	return []FieldChange{change}
+}
+
+func isEqual(x, y any) bool {
+	if tx, ok := x.(time.Time); ok {
+		return tx.Equal(y.(time.Time))
+	}
+	if ax, ok := x.([]Attendee); ok {
+		ay := y.([]Attendee)
+		return len(ax) == len(ay) && (len(ax) == 0 || reflect.DeepEqual(ax, ay))
+	}
+	return x == y
+}
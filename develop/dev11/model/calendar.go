@@ -0,0 +1,20 @@
+package model
+
+// Role - права пользователя на чужой календарь
+type Role string
+
+const (
+	RoleRead  Role = "read"
+	RoleWrite Role = "write"
+)
+
+// Share - владелец OwnerId открыл свой календарь пользователю UserId
+type Share struct {
+	OwnerId uint `json:"owner_id"`
+	UserId  uint `json:"user_id"`
+	Role    Role `json:"role"`
+}
+
+func (r Role) IsValid() bool {
+	return r == RoleRead || r == RoleWrite
+}
@@ -0,0 +1,27 @@
+package model
+
+import "errors"
+
+var (
+	ErrEventNotFound    = errors.New("event not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotInvited       = errors.New("user is not invited to the event")
+	ErrInvalidStatus    = errors.New("invalid invitation status")
+	ErrInvalidRole      = errors.New("invalid calendar role")
+	ErrSelfShare        = errors.New("calendar cannot be shared with its owner")
+	ErrInvalidPeriod    = errors.New("period start must be before its end")
+)
+
+var businessErrors = []error{
+	ErrEventNotFound, ErrPermissionDenied, ErrNotInvited, ErrInvalidStatus, ErrInvalidRole, ErrSelfShare, ErrInvalidPeriod,
+}
+
+// IsBusinessError отличает ошибки бизнес-логики от сбоев хранилища и прочих внутренних ошибок
+func IsBusinessError(err error) bool {
+	for _, e := range businessErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
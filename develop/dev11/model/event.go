@@ -2,10 +2,66 @@ package model
 
 import "time"
 
+// MaxDuration - наибольшая длительность события в минутах, неделя
+const MaxDuration = 7 * 24 * 60
+
 type Event struct {
-	Id          uint      `json:"id,omitempty"`
-	Date        time.Time `json:"date"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	CreatorId   uint      `json:"user_id,omitempty"`
+	Id          uint       `json:"id,omitempty"`
+	Date        time.Time  `json:"date"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	CreatorId   uint       `json:"user_id,omitempty"`
+	Attendees   []Attendee `json:"attendees,omitempty"`
+	// Duration - длительность в минутах, 0 - событие на весь день
+	Duration uint `json:"duration,omitempty"`
+}
+
+// Interval возвращает время, которое занимает событие
+func (e Event) Interval() Interval {
+	if e.Duration == 0 {
+		y, m, d := e.Date.Date()
+		start := time.Date(y, m, d, 0, 0, 0, 0, e.Date.Location())
+		return Interval{Start: start, End: start.AddDate(0, 0, 1)}
+	}
+	return Interval{Start: e.Date, End: e.Date.Add(time.Duration(e.Duration) * time.Minute)}
+}
+
+// IsBusy сообщает, занят ли пользователь во время события: он его создал
+// или приглашен и не отказался
+func (e Event) IsBusy(userId uint) bool {
+	if e.CreatorId == userId {
+		return true
+	}
+	a, ok := e.Attendee(userId)
+	return ok && a.Status != StatusDeclined
+}
+
+// RSVPStatus - ответ участника на приглашение
+type RSVPStatus string
+
+const (
+	StatusPending   RSVPStatus = "pending"
+	StatusAccepted  RSVPStatus = "accepted"
+	StatusDeclined  RSVPStatus = "declined"
+	StatusTentative RSVPStatus = "tentative"
+)
+
+type Attendee struct {
+	UserId uint       `json:"user_id"`
+	Status RSVPStatus `json:"status"`
+}
+
+// Attendee возвращает участника события с указанным id
+func (e Event) Attendee(userId uint) (Attendee, bool) {
+	for _, a := range e.Attendees {
+		if a.UserId == userId {
+			return a, true
+		}
+	}
+	return Attendee{}, false
+}
+
+// IsValidResponse проверяет, что статус может быть ответом на приглашение
+func (s RSVPStatus) IsValidResponse() bool {
+	return s == StatusAccepted || s == StatusDeclined || s == StatusTentative
 }
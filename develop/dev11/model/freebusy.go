@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Interval - полуинтервал времени [Start, End)
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type UserBusy struct {
+	UserId uint       `json:"user_id"`
+	Busy   []Interval `json:"busy"`
+}
+
+// FreeBusy - занятость каждого пользователя и общие свободные окна
+type FreeBusy struct {
+	Users []UserBusy `json:"users"`
+	Free  []Interval `json:"free"`
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"dev11/model"
+	"sync"
+)
+
+// MemoryAuditStore - журнал изменений в памяти, записи можно только добавлять
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	records []model.AuditRecord
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{records: make([]model.AuditRecord, 0)}
+}
+
+func (m *MemoryAuditStore) Append(record model.AuditRecord) (model.AuditRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record.Id = uint(len(m.records)) + 1
+	m.records = append(m.records, record)
+	return record, nil
+}
+
+func (m *MemoryAuditStore) GetByCalendar(ownerId uint) ([]model.AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := make([]model.AuditRecord, 0)
+	for _, record := range m.records {
+		if record.Share != nil && record.Share.OwnerId == ownerId {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (m *MemoryAuditStore) GetByEvent(eventId uint) ([]model.AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := make([]model.AuditRecord, 0)
+	for _, record := range m.records {
+		if record.EventId == eventId {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"dev11/model"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository хранит события и доступы к календарям в памяти
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	lastId uint
+	events map[uint]model.Event
+	// shares[userId][ownerId] - роль userId в календаре ownerId
+	shares map[uint]map[uint]model.Role
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		events: make(map[uint]model.Event),
+		shares: make(map[uint]map[uint]model.Role),
+	}
+}
+
+func (m *MemoryRepository) Add(event model.Event) (model.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastId++
+	event.Id = m.lastId
+	m.events[event.Id] = clone(event)
+	return clone(event), nil
+}
+
+func (m *MemoryRepository) Update(event model.Event) (model.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[event.Id]; !ok {
+		return model.Event{}, model.ErrEventNotFound
+	}
+	m.events[event.Id] = clone(event)
+	return clone(event), nil
+}
+
+func (m *MemoryRepository) Delete(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[id]; !ok {
+		return model.ErrEventNotFound
+	}
+	delete(m.events, id)
+	return nil
+}
+
+// Restore возвращает удаленное событие под прежним id
+func (m *MemoryRepository) Restore(event model.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[event.Id] = clone(event)
+	return nil
+}
+
+func (m *MemoryRepository) GetById(id uint) (model.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	event, ok := m.events[id]
+	if !ok {
+		return model.Event{}, model.ErrEventNotFound
+	}
+	return clone(event), nil
+}
+
+func (m *MemoryRepository) GetByDay(day time.Time) ([]model.Event, error) {
+	from := startOfDay(day)
+	return m.getBetween(from, from.AddDate(0, 0, 1)), nil
+}
+
+func (m *MemoryRepository) GetByWeek(startDay time.Time) ([]model.Event, error) {
+	from := startOfDay(startDay)
+	return m.getBetween(from, from.AddDate(0, 0, 7)), nil
+}
+
+func (m *MemoryRepository) GetByMonth(month time.Month, year int) ([]model.Event, error) {
+	from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return m.getBetween(from, from.AddDate(0, 1, 0)), nil
+}
+
+func (m *MemoryRepository) SetShare(share model.Share) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shares[share.UserId] == nil {
+		m.shares[share.UserId] = make(map[uint]model.Role)
+	}
+	m.shares[share.UserId][share.OwnerId] = share.Role
+	return nil
+}
+
+func (m *MemoryRepository) DeleteShare(ownerId, userId uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.shares[userId], ownerId)
+	return nil
+}
+
+func (m *MemoryRepository) GetShares(userId uint) ([]model.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	shares := make([]model.Share, 0, len(m.shares[userId]))
+	for ownerId, role := range m.shares[userId] {
+		shares = append(shares, model.Share{OwnerId: ownerId, UserId: userId, Role: role})
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].OwnerId < shares[j].OwnerId })
+	return shares, nil
+}
+
+// getBetween возвращает события в полуинтервале [from, to), упорядоченные по дате и id
+func (m *MemoryRepository) getBetween(from, to time.Time) []model.Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events := make([]model.Event, 0)
+	for _, event := range m.events {
+		if !event.Date.Before(from) && event.Date.Before(to) {
+			events = append(events, clone(event))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Date.Equal(events[j].Date) {
+			return events[i].Id < events[j].Id
+		}
+		return events[i].Date.Before(events[j].Date)
+	})
+	return events
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// clone копирует слайс участников, чтобы вызывающий код не мог изменить хранилище
+func clone(event model.Event) model.Event {
+	event.Attendees = slices.Clone(event.Attendees)
+	return event
+}
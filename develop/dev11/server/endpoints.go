@@ -0,0 +1,247 @@
+package server
+
+import (
+	"dev11/model"
+	"net/http"
+	"time"
+)
+
+// endpoint - описание метода API: по нему регистрируется обработчик,
+// валидируются параметры и строится openapi.json
+type endpoint struct {
+	Path    string
+	Method  string
+	Summary string
+	Params  []param
+	// Status - код успешного ответа, Result - схема поля result
+	Status int
+	Result schema
+	handle func(s *Server, p params) (any, error)
+}
+
+var (
+	idParam          = param{Name: "id", Type: typeInteger, Required: true, Description: "Event id"}
+	nameParam        = param{Name: "name", Type: typeString, Required: true, Description: "Event name, cannot be blank"}
+	dateTimeParam    = param{Name: "date", Type: typeDateTime, Required: true, Description: "Event start; a date without time means an all-day event. Cannot be in the past"}
+	descriptionParam = param{Name: "description", Type: typeString, Description: "Event description"}
+	durationParam    = param{Name: "duration", Type: typeInteger, Description: "Event duration in minutes, at most a week. Required for a date with time, not allowed for an all-day event"}
+	attendeesParam   = param{Name: "attendees", Type: typeIdList, Description: "Comma separated ids of invited users, replaces the current list"}
+	userIdParam      = param{Name: "user_id", Type: typeInteger, Required: true, Description: "User id"}
+	actorIdParam     = param{Name: "user_id", Type: typeInteger, Required: true, Description: "Id of the user performing the change: calendar owner or a user with write access"}
+	dateParam        = param{Name: "date", Type: typeDate, Required: true, Description: "Start of the period"}
+	ownerIdParam     = param{Name: "owner_id", Type: typeInteger, Required: true, Description: "Calendar owner id"}
+)
+
+var endpoints = []endpoint{
+	{
+		Path:    "/create_event",
+		Method:  http.MethodPost,
+		Summary: "Create an event in the user's calendar and invite attendees",
+		Params: []param{
+			{Name: "user_id", Type: typeInteger, Required: true, Description: "Calendar owner id"},
+			nameParam, dateTimeParam, descriptionParam, durationParam, attendeesParam,
+		},
+		Status: http.StatusCreated,
+		Result: ref("Event"),
+		handle: (*Server).createEvent,
+	},
+	{
+		Path:    "/update_event",
+		Method:  http.MethodPost,
+		Summary: "Update an event",
+		Params:  []param{idParam, actorIdParam, nameParam, dateTimeParam, descriptionParam, durationParam, attendeesParam},
+		Status:  http.StatusOK,
+		Result:  ref("Event"),
+		handle:  (*Server).updateEvent,
+	},
+	{
+		Path:    "/delete_event",
+		Method:  http.MethodPost,
+		Summary: "Delete an event",
+		Params:  []param{idParam, actorIdParam},
+		Status:  http.StatusOK,
+		Result:  schema{"type": "string"},
+		handle:  (*Server).deleteEvent,
+	},
+	{
+		Path:    "/events_for_day",
+		Method:  http.MethodGet,
+		Summary: "Events visible to the user on the day",
+		Params:  []param{userIdParam, dateParam},
+		Status:  http.StatusOK,
+		Result:  arrayOf(ref("Event")),
+		handle:  (*Server).eventsForDay,
+	},
+	{
+		Path:    "/events_for_week",
+		Method:  http.MethodGet,
+		Summary: "Events visible to the user during seven days starting from the date",
+		Params:  []param{userIdParam, dateParam},
+		Status:  http.StatusOK,
+		Result:  arrayOf(ref("Event")),
+		handle:  (*Server).eventsForWeek,
+	},
+	{
+		Path:    "/events_for_month",
+		Method:  http.MethodGet,
+		Summary: "Events visible to the user during the month of the date",
+		Params:  []param{userIdParam, dateParam},
+		Status:  http.StatusOK,
+		Result:  arrayOf(ref("Event")),
+		handle:  (*Server).eventsForMonth,
+	},
+	{
+		Path:    "/respond_invitation",
+		Method:  http.MethodPost,
+		Summary: "Answer an invitation to an event",
+		Params: []param{
+			{Name: "event_id", Type: typeInteger, Required: true, Description: "Event id"},
+			{Name: "user_id", Type: typeInteger, Required: true, Description: "Invited user id"},
+			{Name: "status", Type: typeString, Required: true, Description: "Answer to the invitation",
+				Enum: []string{string(model.StatusAccepted), string(model.StatusDeclined), string(model.StatusTentative)}},
+		},
+		Status: http.StatusOK,
+		Result: ref("Event"),
+		handle: (*Server).respondInvitation,
+	},
+	{
+		Path:    "/share_calendar",
+		Method:  http.MethodPost,
+		Summary: "Share the owner's calendar with a user",
+		Params: []param{
+			ownerIdParam,
+			{Name: "user_id", Type: typeInteger, Required: true, Description: "Id of the user getting access"},
+			{Name: "role", Type: typeString, Required: true, Description: "Access level",
+				Enum: []string{string(model.RoleRead), string(model.RoleWrite)}},
+		},
+		Status: http.StatusOK,
+		Result: ref("Share"),
+		handle: (*Server).shareCalendar,
+	},
+	{
+		Path:    "/unshare_calendar",
+		Method:  http.MethodPost,
+		Summary: "Revoke the user's access to the owner's calendar",
+		Params: []param{
+			ownerIdParam,
+			{Name: "user_id", Type: typeInteger, Required: true, Description: "Id of the user losing access"},
+		},
+		Status: http.StatusOK,
+		Result: schema{"type": "string"},
+		handle: (*Server).unshareCalendar,
+	},
+	{
+		Path:    "/freebusy",
+		Method:  http.MethodGet,
+		Summary: "Busy intervals of the users and their common free slots",
+		Params: []param{
+			{Name: "user_ids", Type: typeIdList, Required: true, Description: "Comma separated user ids"},
+			{Name: "from", Type: typeDateTime, Required: true, Description: "Start of the period"},
+			{Name: "to", Type: typeDateTime, Required: true, Description: "End of the period; a date without time includes the whole day"},
+			{Name: "min_duration", Type: typeInteger, Default: "30", Description: "Minimal free slot duration in minutes"},
+		},
+		Status: http.StatusOK,
+		Result: ref("FreeBusy"),
+		handle: (*Server).freeBusy,
+	},
+	{
+		Path:    "/audit",
+		Method:  http.MethodGet,
+		Summary: "History of changes of the event, including deleted events",
+		Params: []param{
+			{Name: "event_id", Type: typeInteger, Required: true, Description: "Event id"},
+		},
+		Status: http.StatusOK,
+		Result: arrayOf(ref("AuditRecord")),
+		handle: (*Server).auditLog,
+	},
+	{
+		Path:    "/calendar_audit",
+		Method:  http.MethodGet,
+		Summary: "History of sharing of the owner's calendar",
+		Params:  []param{ownerIdParam},
+		Status:  http.StatusOK,
+		Result:  arrayOf(ref("AuditRecord")),
+		handle:  (*Server).calendarAuditLog,
+	},
+}
+
+func (s *Server) createEvent(p params) (any, error) {
+	event := unmarshalEvent(p)
+	if err := validateEvent(event, p.dateOnly("date")); err != nil {
+		return nil, err
+	}
+	return s.CreateEvent(event)
+}
+
+func (s *Server) updateEvent(p params) (any, error) {
+	event := unmarshalEvent(p)
+	if err := validateEvent(event, p.dateOnly("date")); err != nil {
+		return nil, err
+	}
+	return s.UpdateEvent(event)
+}
+
+func (s *Server) deleteEvent(p params) (any, error) {
+	if err := s.DeleteEvent(unmarshalEvent(p)); err != nil {
+		return nil, err
+	}
+	return "Event deleted", nil
+}
+
+func (s *Server) eventsForDay(p params) (any, error) {
+	return s.EventsForDay(p.uint("user_id"), p.time("date"))
+}
+
+func (s *Server) eventsForWeek(p params) (any, error) {
+	return s.EventsForWeek(p.uint("user_id"), p.time("date"))
+}
+
+func (s *Server) eventsForMonth(p params) (any, error) {
+	year, month, _ := p.time("date").Date()
+	return s.EventsForMonth(p.uint("user_id"), month, year)
+}
+
+func (s *Server) respondInvitation(p params) (any, error) {
+	return s.RespondToInvitation(p.uint("event_id"), p.uint("user_id"), model.RSVPStatus(p.string("status")))
+}
+
+func (s *Server) shareCalendar(p params) (any, error) {
+	share := model.Share{OwnerId: p.uint("owner_id"), UserId: p.uint("user_id"), Role: model.Role(p.string("role"))}
+	if err := s.ShareCalendar(share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (s *Server) unshareCalendar(p params) (any, error) {
+	if err := s.UnshareCalendar(p.uint("owner_id"), p.uint("user_id")); err != nil {
+		return nil, err
+	}
+	return "Calendar unshared", nil
+}
+
+func (s *Server) freeBusy(p params) (any, error) {
+	from, to := p.time("from"), p.time("to")
+	// день без времени в to включается в период целиком
+	if p.dateOnly("to") {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		return nil, validationError("from must be before to")
+	}
+	userIds := p.ids("user_ids")
+	if len(userIds) == 0 {
+		return nil, validationError("user_ids cannot be empty")
+	}
+	minDuration := time.Duration(p.uint("min_duration")) * time.Minute
+	return s.FreeBusy(userIds, from, to, minDuration)
+}
+
+func (s *Server) auditLog(p params) (any, error) {
+	return s.AuditLog(p.uint("event_id"))
+}
+
+func (s *Server) calendarAuditLog(p params) (any, error) {
+	return s.CalendarAuditLog(p.uint("owner_id"))
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type CORSConfig struct {
+	// AllowedOrigins - разрешенные источники, "*" разрешает любой, но только без AllowCredentials
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	// MaxAge - сколько секунд браузер может кешировать ответ на preflight
+	MaxAge int `json:"max_age"`
+}
+
+type CSRFConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost}
+	defaultCORSHeaders = []string{"Content-Type"}
+)
+
+// Validate проверяет настройки: с учетными данными источники перечисляются явно,
+// иначе любой сайт сможет делать запросы от имени пользователя
+func (c CORSConfig) Validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return errors.New(`cors: allowed_origins cannot contain "*" when allow_credentials is enabled`)
+	}
+	return nil
+}
+
+// allowsOrigin проверяет источник для CORS. С учетными данными "*" не действует,
+// даже если настройки не прошли через Validate
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	if slices.Contains(c.AllowedOrigins, origin) {
+		return true
+	}
+	return !c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*")
+}
+
+func (c CORSConfig) methods() []string {
+	if len(c.AllowedMethods) == 0 {
+		return defaultCORSMethods
+	}
+	return c.AllowedMethods
+}
+
+func (c CORSConfig) headers() []string {
+	if len(c.AllowedHeaders) == 0 {
+		return defaultCORSHeaders
+	}
+	return c.AllowedHeaders
+}
+
+func (c CORSConfig) allowsHeaders(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !slices.ContainsFunc(c.headers(), func(allowed string) bool { return strings.EqualFold(allowed, h) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// withCORS отвечает на preflight-запросы и добавляет заголовки CORS для разрешенных источников
+func withCORS(config CORSConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestedMethod != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if !config.allowsOrigin(origin) ||
+				!slices.Contains(config.methods(), requestedMethod) ||
+				!config.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+				log.Printf("CORS preflight from %s rejected", origin)
+				sendError(http.StatusForbidden, "CORS request is not allowed", w)
+				return
+			}
+			setAllowOrigin(config, w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(config.methods(), ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(config.headers(), ", "))
+			if config.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(config.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if config.allowsOrigin(origin) {
+			setAllowOrigin(config, w, origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setAllowOrigin(config CORSConfig, w http.ResponseWriter, origin string) {
+	if config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		return
+	}
+	if slices.Contains(config.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
+
+// withCSRF отклоняет POST-запросы, пришедшие со страниц чужих источников.
+// Браузер не делает preflight для form-encoded POST, поэтому источник проверяется
+// по заголовку Origin, а если его нет - по Referer. Запросы без обоих заголовков
+// отправлены не браузером и пропускаются. Чужой источник должен быть явно перечислен
+// в настройках CORS: "*" разрешает только чтение, но не отправку форм
+func withCSRF(config CSRFConfig, cors CORSConfig, next http.Handler) http.Handler {
+	if !config.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			if referer, err := url.Parse(r.Header.Get("Referer")); err == nil && referer.Host != "" {
+				origin = referer.Scheme + "://" + referer.Host
+			}
+		}
+		if origin != "" && !isSameOrigin(r, origin) && !slices.Contains(cors.AllowedOrigins, origin) {
+			log.Printf("Cross-site POST from %s rejected", origin)
+			sendError(http.StatusForbidden, "Cross-site request rejected", w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
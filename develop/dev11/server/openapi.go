@@ -0,0 +1,301 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// schema - JSON Schema в формате OpenAPI 3
+type schema map[string]any
+
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items schema) schema {
+	return schema{"type": "array", "items": items}
+}
+
+var dateTimeSchema = schema{"type": "string", "format": "date-time"}
+
+var componentSchemas = map[string]schema{
+	"Event": {
+		"type":     "object",
+		"required": []string{"date", "name"},
+		"properties": map[string]schema{
+			"id":          {"type": "integer", "minimum": 0},
+			"date":        dateTimeSchema,
+			"name":        {"type": "string"},
+			"description": {"type": "string"},
+			"user_id":     {"type": "integer", "minimum": 0, "description": "Calendar owner id"},
+			"attendees":   arrayOf(ref("Attendee")),
+			"duration":    {"type": "integer", "minimum": 0, "description": "Duration in minutes, absent for all-day events"},
+		},
+	},
+	"Attendee": {
+		"type":     "object",
+		"required": []string{"user_id", "status"},
+		"properties": map[string]schema{
+			"user_id": {"type": "integer", "minimum": 0},
+			"status":  {"type": "string", "enum": []string{"pending", "accepted", "declined", "tentative"}},
+		},
+	},
+	"Share": {
+		"type":     "object",
+		"required": []string{"owner_id", "user_id", "role"},
+		"properties": map[string]schema{
+			"owner_id": {"type": "integer", "minimum": 0},
+			"user_id":  {"type": "integer", "minimum": 0},
+			"role":     {"type": "string", "enum": []string{"read", "write"}},
+		},
+	},
+	"Interval": {
+		"type":     "object",
+		"required": []string{"start", "end"},
+		"properties": map[string]schema{
+			"start": dateTimeSchema,
+			"end":   dateTimeSchema,
+		},
+	},
+	"FreeBusy": {
+		"type":     "object",
+		"required": []string{"users", "free"},
+		"properties": map[string]schema{
+			"users": arrayOf(schema{
+				"type":     "object",
+				"required": []string{"user_id", "busy"},
+				"properties": map[string]schema{
+					"user_id": {"type": "integer", "minimum": 0},
+					"busy":    arrayOf(ref("Interval")),
+				},
+			}),
+			"free": arrayOf(ref("Interval")),
+		},
+	},
+	"AuditRecord": {
+		"type":     "object",
+		"required": []string{"id", "user_id", "action", "time", "changes"},
+		"properties": map[string]schema{
+			"id":       {"type": "integer", "minimum": 0},
+			"event_id": {"type": "integer", "minimum": 0, "description": "Absent for calendar sharing records"},
+			"user_id":  {"type": "integer", "minimum": 0, "description": "Id of the user who made the change"},
+			"action":   {"type": "string", "enum": []string{"create", "update", "delete", "respond", "share", "unshare"}},
+			"time":     dateTimeSchema,
+			"before":   ref("Event"),
+			"after":    ref("Event"),
+			"share":    ref("Share"),
+			"changes":  arrayOf(ref("FieldChange")),
+		},
+	},
+	"FieldChange": {
+		"type":     "object",
+		"required": []string{"field"},
+		"properties": map[string]schema{
+			"field":  {"type": "string"},
+			"before": {"description": "Value before the change, absent for created events"},
+			"after":  {"description": "Value after the change, absent for deleted events"},
+		},
+	},
+	"RPCRequest": {
+		"type":     "object",
+		"required": []string{"jsonrpc", "method"},
+		"properties": map[string]schema{
+			"jsonrpc": {"type": "string", "enum": []string{"2.0"}},
+			"method":  {"type": "string", "description": "Path of an HTTP endpoint without the leading slash"},
+			"params":  {"type": "object", "description": "Parameters of the HTTP endpoint by name; id lists may be passed as arrays"},
+			"id":      {"description": "Absent for notifications"},
+		},
+	},
+	"RPCResponse": {
+		"type":     "object",
+		"required": []string{"jsonrpc", "id"},
+		"properties": map[string]schema{
+			"jsonrpc": {"type": "string", "enum": []string{"2.0"}},
+			"result":  {"description": "Same as result of the HTTP endpoint"},
+			"error": {
+				"type":     "object",
+				"required": []string{"code", "message"},
+				"properties": map[string]schema{
+					"code":    {"type": "integer"},
+					"message": {"type": "string"},
+					"data":    {"type": "string"},
+				},
+			},
+			"id": {},
+		},
+	},
+	"Error": {
+		"type":       "object",
+		"required":   []string{"error"},
+		"properties": map[string]schema{"error": {"type": "string"}},
+	},
+}
+
+// errorResponses - ответы с ошибками, общие для всех методов
+var errorResponses = map[int]string{
+	http.StatusBadRequest:          "Invalid input parameters",
+	http.StatusMethodNotAllowed:    "HTTP method is not supported by the endpoint",
+	http.StatusInternalServerError: "Internal server error",
+	http.StatusServiceUnavailable:  "Business logic error",
+}
+
+func (spec param) schema() schema {
+	s := schema{}
+	switch spec.Type {
+	case typeInteger:
+		s["type"], s["minimum"] = "integer", 0
+	case typeDate:
+		s["type"], s["format"] = "string", "date"
+	case typeDateTime:
+		s["type"], s["pattern"] = "string", `^\d{4}-\d{2}-\d{2}(T\d{2}:\d{2})?$`
+	case typeIdList:
+		s["type"], s["pattern"] = "string", `^\d+(,\d+)*$`
+	default:
+		s["type"] = "string"
+	}
+	if len(spec.Enum) > 0 {
+		s["enum"] = spec.Enum
+	}
+	if spec.Default != "" {
+		s["default"] = spec.Default
+		if spec.Type == typeInteger {
+			s["default"], _ = strconv.Atoi(spec.Default)
+		}
+	}
+	if spec.Description != "" {
+		s["description"] = spec.Description
+	}
+	return s
+}
+
+func (e endpoint) operation() map[string]any {
+	op := map[string]any{
+		"operationId": strings.TrimPrefix(e.Path, "/"),
+		"summary":     e.Summary,
+	}
+
+	if e.Method == http.MethodGet {
+		parameters := make([]map[string]any, 0, len(e.Params))
+		for _, p := range e.Params {
+			parameters = append(parameters, map[string]any{
+				"name":     p.Name,
+				"in":       "query",
+				"required": p.Required,
+				"schema":   p.schema(),
+			})
+		}
+		op["parameters"] = parameters
+	} else {
+		properties := make(map[string]schema, len(e.Params))
+		required := make([]string, 0)
+		for _, p := range e.Params {
+			properties[p.Name] = p.schema()
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		}
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/x-www-form-urlencoded": map[string]any{
+					"schema": schema{"type": "object", "properties": properties, "required": required},
+				},
+			},
+		}
+	}
+
+	responses := map[string]any{
+		strconv.Itoa(e.Status): map[string]any{
+			"description": "Success",
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": schema{
+						"type":       "object",
+						"required":   []string{"result"},
+						"properties": map[string]schema{"result": e.Result},
+					},
+				},
+			},
+		},
+	}
+	for status, description := range errorResponses {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": ref("Error")},
+			},
+		}
+	}
+	if e.Method == http.MethodPost {
+		responses[strconv.Itoa(http.StatusForbidden)] = map[string]any{
+			"description": "Cross-site request rejected by the CSRF protection",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": ref("Error")},
+			},
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// openAPI строит документ OpenAPI 3 по описаниям endpoints
+func openAPI() map[string]any {
+	paths := make(map[string]any, len(endpoints))
+	for _, e := range endpoints {
+		paths[e.Path] = map[string]any{strings.ToLower(e.Method): e.operation()}
+	}
+	paths["/rpc"] = map[string]any{"post": rpcOperation()}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Calendar API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": componentSchemas},
+	}
+}
+
+func rpcOperation() map[string]any {
+	call := schema{"oneOf": []schema{ref("RPCRequest"), arrayOf(ref("RPCRequest"))}}
+	reply := schema{"oneOf": []schema{ref("RPCResponse"), arrayOf(ref("RPCResponse"))}}
+	return map[string]any{
+		"operationId": "rpc",
+		"summary":     "JSON-RPC 2.0 transport with batch calls and notifications for the methods above",
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": call}},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Responses to calls, errors are reported inside the JSON-RPC envelope",
+				"content":     map[string]any{"application/json": map[string]any{"schema": reply}},
+			},
+			"204": map[string]any{"description": "The request contained only notifications"},
+			"405": map[string]any{
+				"description": errorResponses[http.StatusMethodNotAllowed],
+				"content":     map[string]any{"application/json": map[string]any{"schema": ref("Error")}},
+			},
+		},
+	}
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(http.StatusMethodNotAllowed, "Method not allowed", w)
+		return
+	}
+	resp, err := json.Marshal(openAPI())
+	if err != nil {
+		log.Println(err)
+		sendError(http.StatusInternalServerError, "Internal server error", w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(resp); err != nil {
+		log.Println(err)
+	}
+}
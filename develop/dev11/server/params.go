@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type paramType string
+
+const (
+	typeInteger  paramType = "integer"
+	typeString   paramType = "string"
+	typeDate     paramType = "date"
+	typeDateTime paramType = "datetime"
+	typeIdList   paramType = "id_list"
+)
+
+// param описывает параметр метода API. Из этого же описания строится openapi.json
+type param struct {
+	Name        string
+	Type        paramType
+	Required    bool
+	Description string
+	Enum        []string
+	Default     string
+}
+
+// params - разобранные и провалидированные значения параметров
+type params map[string]any
+
+type timeValue struct {
+	t        time.Time
+	dateOnly bool
+}
+
+func (p params) has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+func (p params) uint(name string) uint {
+	v, _ := p[name].(uint)
+	return v
+}
+
+func (p params) string(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+func (p params) time(name string) time.Time {
+	v, _ := p[name].(timeValue)
+	return v.t
+}
+
+// dateOnly сообщает, что параметр был передан датой без времени
+func (p params) dateOnly(name string) bool {
+	v, _ := p[name].(timeValue)
+	return v.dateOnly
+}
+
+func (p params) ids(name string) []uint {
+	v, _ := p[name].([]uint)
+	return v
+}
+
+// parseParams проверяет значения запроса по описаниям параметров
+func parseParams(specs []param, values url.Values) (params, error) {
+	result := make(params, len(specs))
+	for _, spec := range specs {
+		raw := values.Get(spec.Name)
+		if !values.Has(spec.Name) {
+			if spec.Required {
+				return nil, fmt.Errorf("missing %s parameter", spec.Name)
+			}
+			if spec.Default == "" {
+				continue
+			}
+			raw = spec.Default
+		}
+		value, err := spec.parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		result[spec.Name] = value
+	}
+	return result, nil
+}
+
+func (spec param) parse(raw string) (any, error) {
+	switch spec.Type {
+	case typeInteger:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an integer", spec.Name)
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("%s cannot be negative", spec.Name)
+		}
+		return uint(v), nil
+	case typeString:
+		if len(spec.Enum) > 0 && !slices.Contains(spec.Enum, raw) {
+			return nil, fmt.Errorf("%s must be one of %s", spec.Name, strings.Join(spec.Enum, ", "))
+		}
+		return raw, nil
+	case typeDate:
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a date in format 2006-01-02", spec.Name)
+		}
+		return timeValue{t, true}, nil
+	case typeDateTime:
+		if t, err := time.Parse("2006-01-02", raw); err == nil {
+			return timeValue{t, true}, nil
+		}
+		t, err := time.Parse("2006-01-02T15:04", raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be in format 2006-01-02 or 2006-01-02T15:04", spec.Name)
+		}
+		return timeValue{t, false}, nil
+	case typeIdList:
+		ids := make([]uint, 0)
+		for _, v := range strings.Split(raw, ",") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			id, err := strconv.Atoi(v)
+			if err != nil || id < 0 {
+				return nil, fmt.Errorf("%s must be a comma separated list of ids", spec.Name)
+			}
+			ids = append(ids, uint(id))
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("unknown type %s of parameter %s", spec.Type, spec.Name)
+}
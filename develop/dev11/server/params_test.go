@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_parseParams(t *testing.T) {
+	specs := []param{
+		{Name: "user_id", Type: typeInteger, Required: true},
+		{Name: "date", Type: typeDateTime},
+		{Name: "status", Type: typeString, Enum: []string{"accepted", "declined"}},
+		{Name: "user_ids", Type: typeIdList},
+		{Name: "min_duration", Type: typeInteger, Default: "30"},
+	}
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"Only required", "user_id=3", false},
+		{"All parameters", "user_id=3&date=2030-01-02T10:30&status=declined&user_ids=1,2&min_duration=15", false},
+		{"Missing required", "date=2030-01-02", true},
+		{"Negative integer", "user_id=-1", true},
+		{"Not an integer", "user_id=abc", true},
+		{"Bad date", "user_id=1&date=02.01.2030", true},
+		{"Value outside enum", "user_id=1&status=maybe", true},
+		{"Bad id list", "user_id=1&user_ids=1,x", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			_, err := parseParams(specs, values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseParamsValues(t *testing.T) {
+	specs := []param{
+		{Name: "user_id", Type: typeInteger, Required: true},
+		{Name: "date", Type: typeDateTime},
+		{Name: "user_ids", Type: typeIdList},
+		{Name: "min_duration", Type: typeInteger, Default: "30"},
+	}
+	values, _ := url.ParseQuery("user_id=7&date=2030-01-02&user_ids=4,5")
+	p, err := parseParams(specs, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.uint("user_id") != 7 {
+		t.Errorf("user_id = %d, want 7", p.uint("user_id"))
+	}
+	if !p.time("date").Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)) || !p.dateOnly("date") {
+		t.Errorf("date = %v, dateOnly %v", p.time("date"), p.dateOnly("date"))
+	}
+	if ids := p.ids("user_ids"); len(ids) != 2 || ids[0] != 4 || ids[1] != 5 {
+		t.Errorf("user_ids = %v, want [4 5]", ids)
+	}
+	if p.uint("min_duration") != 30 {
+		t.Errorf("min_duration = %d, want default 30", p.uint("min_duration"))
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"dev11/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Коды ошибок JSON-RPC 2.0
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	// rpcBusinessError - ошибка бизнес-логики, аналог HTTP 503
+	rpcBusinessError = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	// ID равен nil, если поле отсутствует: такой запрос - уведомление и ответа на него нет
+	ID json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcMethods - методы JSON-RPC, имя метода совпадает с путем HTTP API без "/"
+var rpcMethods = func() map[string]endpoint {
+	methods := make(map[string]endpoint, len(endpoints))
+	for _, e := range endpoints {
+		methods[strings.TrimPrefix(e.Path, "/")] = e
+	}
+	return methods
+}()
+
+// serveRPC обрабатывает одиночные и пакетные вызовы JSON-RPC 2.0 поверх HTTP POST
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Println("Method not allowed on /rpc")
+		sendError(http.StatusMethodNotAllowed, "Method not allowed", w)
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeRPC(w, newRPCError(nil, rpcParseError, "Parse error", err.Error()))
+		return
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			writeRPC(w, newRPCError(nil, rpcParseError, "Parse error", err.Error()))
+			return
+		}
+		if len(batch) == 0 {
+			writeRPC(w, newRPCError(nil, rpcInvalidRequest, "Invalid Request", "empty batch"))
+			return
+		}
+		responses := make([]rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			if resp, ok := s.callRPC(raw); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeRPC(w, responses)
+		return
+	}
+
+	resp, ok := s.callRPC(body)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRPC(w, resp)
+}
+
+// callRPC выполняет один вызов, ok == false означает уведомление, на которое не нужно отвечать
+func (s *Server) callRPC(raw json.RawMessage) (resp rpcResponse, ok bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return newRPCError(nil, rpcInvalidRequest, "Invalid Request", ""), true
+	}
+	isNotification := req.ID == nil
+
+	e, found := rpcMethods[req.Method]
+	if !found {
+		return newRPCError(req.ID, rpcMethodNotFound, "Method not found", req.Method), !isNotification
+	}
+
+	values, err := rpcValues(req.Params)
+	if err != nil {
+		return newRPCError(req.ID, rpcInvalidParams, "Invalid params", err.Error()), !isNotification
+	}
+	p, err := parseParams(e.Params, values)
+	if err != nil {
+		return newRPCError(req.ID, rpcInvalidParams, "Invalid params", err.Error()), !isNotification
+	}
+
+	result, err := e.handle(s, p)
+	var vErr validationError
+	switch {
+	case errors.As(err, &vErr):
+		return newRPCError(req.ID, rpcInvalidParams, "Invalid params", err.Error()), !isNotification
+	case err != nil && model.IsBusinessError(err):
+		return newRPCError(req.ID, rpcBusinessError, "Business logic error", err.Error()), !isNotification
+	case err != nil:
+		log.Println(err)
+		return newRPCError(req.ID, rpcInternalError, "Internal error", ""), !isNotification
+	}
+	log.Printf("rpc %s handled successfully", req.Method)
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+		return newRPCError(req.ID, rpcInternalError, "Internal error", ""), !isNotification
+	}
+	return rpcResponse{JSONRPC: "2.0", Result: encoded, ID: req.ID}, !isNotification
+}
+
+// rpcValues приводит именованные параметры вызова к виду параметров HTTP-запроса,
+// чтобы провалидировать их по тем же описаниям: числа и строки передаются как есть,
+// массивы превращаются в списки через запятую
+func rpcValues(raw json.RawMessage) (url.Values, error) {
+	values := make(url.Values)
+	if len(raw) == 0 || string(raw) == "null" {
+		return values, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var params map[string]any
+	if err := decoder.Decode(&params); err != nil {
+		return nil, errors.New("params must be an object")
+	}
+	for name, v := range params {
+		value, err := rpcValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values.Set(name, value)
+	}
+	return values, nil
+}
+
+func rpcValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := rpcValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", errors.New("unsupported value type")
+}
+
+func newRPCError(id json.RawMessage, code int, message, data string) rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: message, Data: data}, ID: id}
+}
+
+func writeRPC(w http.ResponseWriter, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		sendError(http.StatusInternalServerError, "Internal server error", w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(resp); err != nil {
+		log.Println(err)
+	}
+}
@@ -3,10 +3,10 @@ package server
 import (
 	"dev11/model"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -18,325 +18,105 @@ type EventSvc interface {
 	EventsForDay(userId uint, day time.Time) ([]model.Event, error)
 	EventsForWeek(userId uint, startDay time.Time) ([]model.Event, error)
 	EventsForMonth(userId uint, month time.Month, year int) ([]model.Event, error)
+	RespondToInvitation(eventId, userId uint, status model.RSVPStatus) (model.Event, error)
+	ShareCalendar(share model.Share) error
+	UnshareCalendar(ownerId, userId uint) error
+	FreeBusy(userIds []uint, from, to time.Time, minDuration time.Duration) (model.FreeBusy, error)
+	AuditLog(eventId uint) ([]model.AuditRecord, error)
+	CalendarAuditLog(ownerId uint) ([]model.AuditRecord, error)
 }
 
 type Server struct {
 	EventSvc
+	CORS CORSConfig
+	CSRF CSRFConfig
 }
 
 func NewServer(repository EventSvc) *Server {
-	return &Server{repository}
+	return &Server{EventSvc: repository}
 }
 
 func (s *Server) Start(port uint16) {
-	http.HandleFunc("/create_event", s.createEvent)
-	http.HandleFunc("/update_event", s.updateEvent)
-	http.HandleFunc("/delete_event", s.deleteEvent)
-	http.HandleFunc("/events_for_day", s.eventsForDay)
-	http.HandleFunc("/events_for_week", s.eventsForWeek)
-	http.HandleFunc("/events_for_month", s.eventsForMonth)
-
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil); err != nil {
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), s.Handler()); err != nil {
 		log.Fatalf("Error starting server: %s", err)
 	}
 }
 
-func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		log.Println("Method not allowed on /create_event")
-		sendError(http.StatusMethodNotAllowed, "Method not allowed", w)
-		return
+// Handler возвращает маршрутизатор со всеми методами API, /openapi.json и /rpc,
+// обернутый в CORS и CSRF middleware
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, e := range endpoints {
+		mux.HandleFunc(e.Path, s.handle(e))
 	}
-
-	var event model.Event
-	err := unmarshalEvent(r, &event)
-	if err != nil {
-		log.Println("Error unmarshalling request body on /create_event")
-		sendError(http.StatusBadRequest, "Bad request body", w)
-		return
-	}
-	if err = validateEvent(event); err != nil {
-		log.Println(err)
-		sendError(http.StatusBadRequest, err.Error(), w)
-		return
-	}
-
-	createdEvent, err := s.CreateEvent(event)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusServiceUnavailable, "Internal server error", w)
-		return
-	}
-
-	result := successResponse{createdEvent}
-	resp, err := json.Marshal(result)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusInternalServerError, "Internal server error", w)
-		return
-	}
-
-	w.WriteHeader(http.StatusCreated)
-	_, err = w.Write(resp)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	log.Println("Event created successfully")
+	mux.HandleFunc("/openapi.json", serveOpenAPI)
+	mux.HandleFunc("/rpc", s.serveRPC)
+	return withCORS(s.CORS, withCSRF(s.CSRF, s.CORS, mux))
 }
 
-func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		log.Println("Method not allowed on /update_event")
-		sendError(http.StatusMethodNotAllowed, "Method not allowed", w)
-		return
-	}
-
-	var event model.Event
-	err := unmarshalEvent(r, &event)
-	if err != nil {
-		log.Println("Error unmarshalling request body on /update_event")
-		sendError(http.StatusBadRequest, "Bad request body", w)
-		return
-	}
-
-	if err = validateEvent(event); err != nil {
-		log.Println(err)
-		sendError(http.StatusBadRequest, err.Error(), w)
-		return
-	}
-
-	updatedEvent, err := s.UpdateEvent(event)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusServiceUnavailable, "Internal server error", w)
-		return
-	}
-
-	result := successResponse{updatedEvent}
-	resp, err := json.Marshal(result)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusInternalServerError, "Internal server error", w)
-		return
-	}
-
-	_, err = w.Write(resp)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	log.Println("Event updated successfully")
-}
-
-func (s *Server) deleteEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		log.Println("Method not allowed on /delete_event")
-		sendError(http.StatusMethodNotAllowed, "Method not allowed", w)
-		return
-	}
-
-	var event model.Event
-	err := unmarshalEvent(r, &event)
-	if err != nil {
-		log.Println("Error unmarshalling request body on /update_event")
-		sendError(http.StatusBadRequest, "Bad request body", w)
-		return
-	}
-
-	err = s.DeleteEvent(event)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusServiceUnavailable, err.Error(), w)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write([]byte("Event deleted"))
-	log.Println("Event deleted successfully")
-}
+// handle проверяет метод и параметры запроса по описанию endpoint и отдает результат в JSON
+func (s *Server) handle(e endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != e.Method {
+			log.Printf("Method not allowed on %s", e.Path)
+			sendError(http.StatusMethodNotAllowed, "Method not allowed", w)
+			return
+		}
 
-func (s *Server) eventsForDay(w http.ResponseWriter, r *http.Request) {
-	var userId uint
-	var date string
-	if r.URL.Query().Has("user_id") {
-		id, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		values := r.URL.Query()
+		if e.Method == http.MethodPost {
+			if err := r.ParseForm(); err != nil {
+				log.Printf("Error parsing request body on %s", e.Path)
+				sendError(http.StatusBadRequest, "Bad request body", w)
+				return
+			}
+			values = r.PostForm
+		}
+		p, err := parseParams(e.Params, values)
 		if err != nil {
-			log.Println("Error parsing user_id parameter")
-			sendError(http.StatusBadRequest, "Bad request", w)
+			log.Println(err)
+			sendError(http.StatusBadRequest, err.Error(), w)
 			return
 		}
-		if id < 0 {
-			log.Println("user_id cannot be negative")
-			sendError(http.StatusBadRequest, "Bad request", w)
+
+		result, err := e.handle(s, p)
+		var vErr validationError
+		if errors.As(err, &vErr) {
+			log.Println(err)
+			sendError(http.StatusBadRequest, err.Error(), w)
 			return
 		}
-		userId = uint(id)
-	} else {
-		log.Println("Missing user_id parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	if r.URL.Query().Has("date") {
-		date = r.URL.Query().Get("date")
-	} else {
-		log.Println("Missing date parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	day, err := time.Parse("2006-01-02", date)
-	if err != nil {
-		log.Println("Error parsing date parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	events, err := s.EventsForDay(userId, day)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusServiceUnavailable, err.Error(), w)
-		return
-	}
-
-	result := successResponse{events}
-	resp, err := json.Marshal(result)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusInternalServerError, err.Error(), w)
-		return
-	}
-
-	_, err = w.Write(resp)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	log.Println("Events for day retrieved successfully")
-}
-
-func (s *Server) eventsForWeek(w http.ResponseWriter, r *http.Request) {
-	var userId uint
-	var date string
-	if r.URL.Query().Has("user_id") {
-		id, err := strconv.Atoi(r.URL.Query().Get("user_id"))
-		if err != nil {
-			log.Println("Error parsing user_id parameter")
-			sendError(http.StatusBadRequest, "Bad request", w)
+		if err != nil && model.IsBusinessError(err) {
+			log.Println(err)
+			sendError(http.StatusServiceUnavailable, err.Error(), w)
 			return
 		}
-		if id < 0 {
-			log.Println("user_id cannot be negative")
-			sendError(http.StatusBadRequest, "Bad request", w)
+		if err != nil {
+			log.Println(err)
+			sendError(http.StatusInternalServerError, "Internal server error", w)
 			return
 		}
-		userId = uint(id)
-	} else {
-		log.Println("Missing user_id parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	if r.URL.Query().Has("date") {
-		date = r.URL.Query().Get("date")
-	} else {
-		log.Println("Missing date parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	day, err := time.Parse("2006-01-02", date)
-	if err != nil {
-		log.Println("Error parsing date parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
 
-	events, err := s.EventsForWeek(userId, day)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusServiceUnavailable, err.Error(), w)
-		return
-	}
-
-	result := successResponse{events}
-	resp, err := json.Marshal(result)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusInternalServerError, err.Error(), w)
-		return
-	}
-
-	_, err = w.Write(resp)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	log.Println("Events for week retrieved successfully")
-}
-
-func (s *Server) eventsForMonth(w http.ResponseWriter, r *http.Request) {
-	var userId uint
-	var date string
-	if r.URL.Query().Has("user_id") {
-		id, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		resp, err := json.Marshal(successResponse{result})
 		if err != nil {
-			log.Println("Error parsing user_id parameter")
-			sendError(http.StatusBadRequest, "Bad request", w)
+			log.Println(err)
+			sendError(http.StatusInternalServerError, "Internal server error", w)
 			return
 		}
-		if id < 0 {
-			log.Println("user_id cannot be negative")
-			sendError(http.StatusBadRequest, "Bad request", w)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.Status)
+		_, err = w.Write(resp)
+		if err != nil {
+			log.Println(err)
 			return
 		}
-		userId = uint(id)
-	} else {
-		log.Println("Missing user_id parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	if r.URL.Query().Has("date") {
-		date = r.URL.Query().Get("date")
-	} else {
-		log.Println("Missing date parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
+		log.Printf("%s handled successfully", e.Path)
 	}
-
-	day, err := time.Parse("2006-01-02", date)
-	if err != nil {
-		log.Println("Error parsing date parameter")
-		sendError(http.StatusBadRequest, "Bad request", w)
-		return
-	}
-
-	year, month, _ := day.Date()
-	events, err := s.EventsForMonth(userId, month, year)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusServiceUnavailable, err.Error(), w)
-		return
-	}
-
-	result := successResponse{events}
-	resp, err := json.Marshal(result)
-	if err != nil {
-		log.Println(err)
-		sendError(http.StatusInternalServerError, err.Error(), w)
-		return
-	}
-
-	_, err = w.Write(resp)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	log.Println("Events for month retrieved successfully")
 }
 
 func sendError(status int, errorString string, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	errResp := errorResponse{errorString}
 	resp, _ := json.Marshal(errResp)
@@ -346,62 +126,61 @@ func sendError(status int, errorString string, w http.ResponseWriter) {
 	}
 }
 
-func validateEvent(event model.Event) error {
+// validationError - ошибка входных данных, на нее сервер отвечает 400
+type validationError string
+
+func (e validationError) Error() string {
+	return string(e)
+}
+
+// validateEvent проверяет событие. allDay - дата передана без времени: только такое
+// событие занимает весь день, поэтому длительность у него не задается, а у события
+// со временем обязательна
+func validateEvent(event model.Event, allDay bool) error {
 	y, m, d := event.Date.Date()
 	yn, mn, dn := time.Now().Date()
 	if y < yn {
-		return fmt.Errorf("date cannot be in the past")
+		return validationError("date cannot be in the past")
 	}
 	if y == yn && m < mn {
-		return fmt.Errorf("date cannot be in the past")
+		return validationError("date cannot be in the past")
 	}
 	if y == yn && m == mn && d < dn {
-		return fmt.Errorf("date cannot be in the past")
+		return validationError("date cannot be in the past")
 	}
 
 	name := strings.Trim(event.Name, " ")
 	if name == "" {
-		return fmt.Errorf("name of event cannot be empty")
-	}
-	return nil
-}
-
-func unmarshalEvent(r *http.Request, event *model.Event) error {
-	format := "2006-01-02"
-	if r.Form.Has("id") {
-		id, err := strconv.Atoi(r.FormValue("id"))
-		if err != nil {
-			return err
-		}
-		if id < 0 {
-			return fmt.Errorf("id cannot be negative")
-		}
-		event.Id = uint(id)
+		return validationError("name of event cannot be empty")
 	}
-	if r.Form.Has("name") {
-		event.Name = r.FormValue("name")
+	if event.Duration > model.MaxDuration {
+		return validationError("duration cannot be longer than a week")
 	}
-	if r.Form.Has("date") {
-		date, err := time.Parse(format, r.FormValue("date"))
-		if err != nil {
-			return err
-		}
-		event.Date = date
+	if allDay && event.Duration != 0 {
+		return validationError("an all-day event cannot have a duration")
 	}
-	if r.Form.Has("description") {
-		event.Description = r.FormValue("description")
+	if !allDay && event.Duration == 0 {
+		return validationError("duration is required for an event with a time")
 	}
-	if r.Form.Has("user_id") {
-		id, err := strconv.Atoi(r.FormValue("user_id"))
-		if err != nil {
-			return err
-		}
-		if id < 0 {
-			return fmt.Errorf("user_id cannot be negative")
+	return nil
+}
+
+func unmarshalEvent(p params) model.Event {
+	event := model.Event{
+		Id:          p.uint("id"),
+		Date:        p.time("date"),
+		Name:        p.string("name"),
+		Description: p.string("description"),
+		CreatorId:   p.uint("user_id"),
+		Duration:    p.uint("duration"),
+	}
+	if p.has("attendees") {
+		event.Attendees = make([]model.Attendee, 0)
+		for _, id := range p.ids("attendees") {
+			event.Attendees = append(event.Attendees, model.Attendee{UserId: id, Status: model.StatusPending})
 		}
-		event.CreatorId = uint(id)
 	}
-	return nil
+	return event
 }
 
 type errorResponse struct {
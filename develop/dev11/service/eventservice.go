@@ -0,0 +1,352 @@
+package service
+
+import (
+	"dev11/model"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Repository interface {
+	Add(event model.Event) (model.Event, error)
+	Update(event model.Event) (model.Event, error)
+	Delete(id uint) error
+	// Restore возвращает удаленное событие под прежним id
+	Restore(event model.Event) error
+	GetById(id uint) (model.Event, error)
+	GetByDay(day time.Time) ([]model.Event, error)
+	GetByWeek(startDay time.Time) ([]model.Event, error)
+	GetByMonth(month time.Month, year int) ([]model.Event, error)
+	SetShare(share model.Share) error
+	DeleteShare(ownerId, userId uint) error
+	GetShares(userId uint) ([]model.Share, error)
+}
+
+// AuditStore - журнал изменений событий и доступов, в который можно только добавлять записи
+type AuditStore interface {
+	Append(record model.AuditRecord) (model.AuditRecord, error)
+	GetByEvent(eventId uint) ([]model.AuditRecord, error)
+	GetByCalendar(ownerId uint) ([]model.AuditRecord, error)
+}
+
+type EventService struct {
+	repo  Repository
+	audit AuditStore
+	now   func() time.Time
+	// mu делает атомарными изменения вида "прочитать событие - изменить - сохранить"
+	// вместе с записью о них в журнале
+	mu sync.Mutex
+}
+
+func NewEventService(repo Repository, audit AuditStore) *EventService {
+	return &EventService{repo: repo, audit: audit, now: time.Now}
+}
+
+// CreateEvent создает событие в календаре event.CreatorId и рассылает приглашения участникам
+func (s *EventService) CreateEvent(event model.Event) (model.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.Attendees = mergeAttendees(nil, event.Attendees, event.CreatorId)
+	created, err := s.repo.Add(event)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if err = s.record(model.ActionCreate, event.CreatorId, nil, &created); err != nil {
+		return model.Event{}, rollback(err, func() error { return s.repo.Delete(created.Id) })
+	}
+	return created, nil
+}
+
+// UpdateEvent изменяет событие. event.CreatorId - пользователь, выполняющий изменение:
+// владелец календаря или пользователь с правом записи в него
+func (s *EventService) UpdateEvent(event model.Event) (model.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.repo.GetById(event.Id)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if err = s.checkWrite(event.CreatorId, stored.CreatorId); err != nil {
+		return model.Event{}, err
+	}
+
+	before := stored
+	stored.Name = event.Name
+	stored.Date = event.Date
+	stored.Description = event.Description
+	stored.Duration = event.Duration
+	if event.Attendees != nil {
+		stored.Attendees = mergeAttendees(stored.Attendees, event.Attendees, stored.CreatorId)
+	}
+	updated, err := s.repo.Update(stored)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if err = s.record(model.ActionUpdate, event.CreatorId, &before, &updated); err != nil {
+		return model.Event{}, rollback(err, func() error { _, err := s.repo.Update(before); return err })
+	}
+	return updated, nil
+}
+
+// DeleteEvent удаляет событие, event.CreatorId - пользователь, выполняющий удаление
+func (s *EventService) DeleteEvent(event model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.repo.GetById(event.Id)
+	if err != nil {
+		return err
+	}
+	if err = s.checkWrite(event.CreatorId, stored.CreatorId); err != nil {
+		return err
+	}
+	if err = s.repo.Delete(stored.Id); err != nil {
+		return err
+	}
+	if err = s.record(model.ActionDelete, event.CreatorId, &stored, nil); err != nil {
+		return rollback(err, func() error { return s.repo.Restore(stored) })
+	}
+	return nil
+}
+
+func (s *EventService) EventsForDay(userId uint, day time.Time) ([]model.Event, error) {
+	events, err := s.repo.GetByDay(day)
+	if err != nil {
+		return nil, err
+	}
+	return s.visibleTo(userId, events)
+}
+
+func (s *EventService) EventsForWeek(userId uint, startDay time.Time) ([]model.Event, error) {
+	events, err := s.repo.GetByWeek(startDay)
+	if err != nil {
+		return nil, err
+	}
+	return s.visibleTo(userId, events)
+}
+
+func (s *EventService) EventsForMonth(userId uint, month time.Month, year int) ([]model.Event, error) {
+	events, err := s.repo.GetByMonth(month, year)
+	if err != nil {
+		return nil, err
+	}
+	return s.visibleTo(userId, events)
+}
+
+// RespondToInvitation сохраняет ответ приглашенного пользователя
+func (s *EventService) RespondToInvitation(eventId, userId uint, status model.RSVPStatus) (model.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !status.IsValidResponse() {
+		return model.Event{}, model.ErrInvalidStatus
+	}
+	event, err := s.repo.GetById(eventId)
+	if err != nil {
+		return model.Event{}, err
+	}
+	before := event
+	event.Attendees = slices.Clone(event.Attendees)
+	for i := range event.Attendees {
+		if event.Attendees[i].UserId == userId {
+			event.Attendees[i].Status = status
+			updated, err := s.repo.Update(event)
+			if err != nil {
+				return model.Event{}, err
+			}
+			if err = s.record(model.ActionRespond, userId, &before, &updated); err != nil {
+				return model.Event{}, rollback(err, func() error { _, err := s.repo.Update(before); return err })
+			}
+			return updated, nil
+		}
+	}
+	return model.Event{}, model.ErrNotInvited
+}
+
+// AuditLog возвращает историю изменений события, в том числе удаленного
+func (s *EventService) AuditLog(eventId uint) ([]model.AuditRecord, error) {
+	return s.audit.GetByEvent(eventId)
+}
+
+// CalendarAuditLog возвращает историю доступов к календарю ownerId
+func (s *EventService) CalendarAuditLog(ownerId uint) ([]model.AuditRecord, error) {
+	return s.audit.GetByCalendar(ownerId)
+}
+
+// ShareCalendar открывает календарь share.OwnerId пользователю share.UserId
+func (s *EventService) ShareCalendar(share model.Share) error {
+	if !share.Role.IsValid() {
+		return model.ErrInvalidRole
+	}
+	if share.OwnerId == share.UserId {
+		return model.ErrSelfShare
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, err := s.share(share.OwnerId, share.UserId)
+	if err != nil {
+		return err
+	}
+	if err = s.repo.SetShare(share); err != nil {
+		return err
+	}
+	if err = s.recordShare(model.ActionShare, before, &share); err != nil {
+		return rollback(err, func() error { return s.restoreShare(share, before) })
+	}
+	return nil
+}
+
+// UnshareCalendar закрывает пользователю userId доступ к календарю ownerId
+func (s *EventService) UnshareCalendar(ownerId, userId uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, err := s.share(ownerId, userId)
+	if err != nil {
+		return err
+	}
+	if err = s.repo.DeleteShare(ownerId, userId); err != nil {
+		return err
+	}
+	if before == nil {
+		return nil
+	}
+	if err = s.recordShare(model.ActionUnshare, before, nil); err != nil {
+		return rollback(err, func() error { return s.repo.SetShare(*before) })
+	}
+	return nil
+}
+
+// record добавляет в журнал запись об изменении события пользователем userId
+func (s *EventService) record(action model.AuditAction, userId uint, before, after *model.Event) error {
+	record := model.AuditRecord{
+		UserId:  userId,
+		Action:  action,
+		Time:    s.now().UTC(),
+		Before:  before,
+		After:   after,
+		Changes: model.Diff(before, after),
+	}
+	if after != nil {
+		record.EventId = after.Id
+	} else {
+		record.EventId = before.Id
+	}
+	_, err := s.audit.Append(record)
+	return err
+}
+
+// recordShare добавляет в журнал запись об изменении доступа к календарю. Пользователь -
+// владелец календаря: только он открывает и закрывает доступ
+func (s *EventService) recordShare(action model.AuditAction, before, after *model.Share) error {
+	share := after
+	if share == nil {
+		share = before
+	}
+	_, err := s.audit.Append(model.AuditRecord{
+		UserId:  share.OwnerId,
+		Action:  action,
+		Time:    s.now().UTC(),
+		Share:   share,
+		Changes: model.ShareDiff(before, after),
+	})
+	return err
+}
+
+// share возвращает доступ userId к календарю ownerId или nil, если его нет
+func (s *EventService) share(ownerId, userId uint) (*model.Share, error) {
+	shares, err := s.repo.GetShares(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		if share.OwnerId == ownerId {
+			return &share, nil
+		}
+	}
+	return nil, nil
+}
+
+// restoreShare возвращает доступ, который был до изменения share
+func (s *EventService) restoreShare(share model.Share, before *model.Share) error {
+	if before == nil {
+		return s.repo.DeleteShare(share.OwnerId, share.UserId)
+	}
+	return s.repo.SetShare(*before)
+}
+
+// rollback отменяет изменение, запись о котором не попала в журнал: изменение
+// и запись сохраняются только вместе
+func rollback(err error, undo func() error) error {
+	if undoErr := undo(); undoErr != nil {
+		return errors.Join(err, undoErr)
+	}
+	return err
+}
+
+// visibleTo оставляет события, которые пользователь создал, на которые приглашен
+// или которые находятся в открытых ему календарях
+func (s *EventService) visibleTo(userId uint, events []model.Event) ([]model.Event, error) {
+	shares, err := s.repo.GetShares(userId)
+	if err != nil {
+		return nil, err
+	}
+	shared := make(map[uint]struct{}, len(shares))
+	for _, share := range shares {
+		shared[share.OwnerId] = struct{}{}
+	}
+
+	result := make([]model.Event, 0, len(events))
+	for _, event := range events {
+		_, isShared := shared[event.CreatorId]
+		_, isInvited := event.Attendee(userId)
+		if event.CreatorId == userId || isShared || isInvited {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (s *EventService) checkWrite(userId, ownerId uint) error {
+	if userId == ownerId {
+		return nil
+	}
+	shares, err := s.repo.GetShares(userId)
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if share.OwnerId == ownerId && share.Role == model.RoleWrite {
+			return nil
+		}
+	}
+	return model.ErrPermissionDenied
+}
+
+// mergeAttendees формирует новый список участников: уже приглашенные сохраняют свой ответ,
+// новые получают статус pending, владелец события и повторы отбрасываются
+func mergeAttendees(old, invited []model.Attendee, ownerId uint) []model.Attendee {
+	statuses := make(map[uint]model.RSVPStatus, len(old))
+	for _, a := range old {
+		statuses[a.UserId] = a.Status
+	}
+	result := make([]model.Attendee, 0, len(invited))
+	seen := make(map[uint]struct{}, len(invited))
+	for _, a := range invited {
+		if _, ok := seen[a.UserId]; ok || a.UserId == ownerId {
+			continue
+		}
+		seen[a.UserId] = struct{}{}
+		status, ok := statuses[a.UserId]
+		if !ok {
+			status = model.StatusPending
+		}
+		result = append(result, model.Attendee{UserId: a.UserId, Status: status})
+	}
+	return result
+}
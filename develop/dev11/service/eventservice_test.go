@@ -0,0 +1,212 @@
+package service
+
+import (
+	"dev11/model"
+	"dev11/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testDay = time.Date(2030, time.March, 4, 0, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*EventService, model.Event) {
+	t.Helper()
+	svc := NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore())
+	event, err := svc.CreateEvent(model.Event{
+		Date:      testDay,
+		Name:      "planning",
+		CreatorId: 1,
+		Attendees: []model.Attendee{{UserId: 2}, {UserId: 2}, {UserId: 1}, {UserId: 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, event
+}
+
+func TestEventService_CreateEvent(t *testing.T) {
+	_, event := newTestService(t)
+	want := []model.Attendee{{UserId: 2, Status: model.StatusPending}, {UserId: 3, Status: model.StatusPending}}
+	if len(event.Attendees) != len(want) {
+		t.Fatalf("CreateEvent() attendees = %v, want %v", event.Attendees, want)
+	}
+	for i := range want {
+		if event.Attendees[i] != want[i] {
+			t.Errorf("CreateEvent() attendees = %v, want %v", event.Attendees, want)
+		}
+	}
+}
+
+func TestEventService_EventsForDay(t *testing.T) {
+	svc, _ := newTestService(t)
+	if err := svc.ShareCalendar(model.Share{OwnerId: 1, UserId: 4, Role: model.RoleRead}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		userId uint
+		want   int
+	}{
+		{"Creator sees own event", 1, 1},
+		{"Attendee sees invitation", 2, 1},
+		{"Shared calendar is visible", 4, 1},
+		{"Stranger sees nothing", 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.EventsForDay(tt.userId, testDay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("EventsForDay() got %d events, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestEventService_RespondToInvitation(t *testing.T) {
+	svc, event := newTestService(t)
+	tests := []struct {
+		name    string
+		userId  uint
+		status  model.RSVPStatus
+		wantErr error
+	}{
+		{"Attendee accepts", 2, model.StatusAccepted, nil},
+		{"Attendee is tentative", 3, model.StatusTentative, nil},
+		{"Not invited user", 5, model.StatusAccepted, model.ErrNotInvited},
+		{"Pending is not a response", 2, model.StatusPending, model.ErrInvalidStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.RespondToInvitation(event.Id, tt.userId, tt.status)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RespondToInvitation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if a, _ := got.Attendee(tt.userId); a.Status != tt.status {
+				t.Errorf("RespondToInvitation() status = %v, want %v", a.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestEventService_UpdateEvent(t *testing.T) {
+	svc, event := newTestService(t)
+	if err := svc.ShareCalendar(model.Share{OwnerId: 1, UserId: 4, Role: model.RoleRead}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ShareCalendar(model.Share{OwnerId: 1, UserId: 5, Role: model.RoleWrite}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.RespondToInvitation(event.Id, 2, model.StatusAccepted); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userId  uint
+		wantErr error
+	}{
+		{"Owner updates", 1, nil},
+		{"Writer updates", 5, nil},
+		{"Reader cannot update", 4, model.ErrPermissionDenied},
+		{"Attendee cannot update", 2, model.ErrPermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := model.Event{Id: event.Id, Date: testDay, Name: "retro", CreatorId: tt.userId}
+			got, err := svc.UpdateEvent(update)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.CreatorId != 1 {
+				t.Errorf("UpdateEvent() changed owner to %d", got.CreatorId)
+			}
+			if a, _ := got.Attendee(2); a.Status != model.StatusAccepted {
+				t.Errorf("UpdateEvent() lost attendee response, got %v", a.Status)
+			}
+		})
+	}
+}
+
+// failingAuditStore отказывает в записи, пока fail установлен
+type failingAuditStore struct {
+	*repository.MemoryAuditStore
+	fail bool
+}
+
+func (f *failingAuditStore) Append(record model.AuditRecord) (model.AuditRecord, error) {
+	if f.fail {
+		return model.AuditRecord{}, errAudit
+	}
+	return f.MemoryAuditStore.Append(record)
+}
+
+var errAudit = errors.New("audit is unavailable")
+
+func TestEventService_AuditFailure(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	audit := &failingAuditStore{MemoryAuditStore: repository.NewMemoryAuditStore()}
+	svc := NewEventService(repo, audit)
+	event, err := svc.CreateEvent(model.Event{Date: testDay, Name: "planning", CreatorId: 1, Attendees: []model.Attendee{{UserId: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.ShareCalendar(model.Share{OwnerId: 1, UserId: 4, Role: model.RoleRead}); err != nil {
+		t.Fatal(err)
+	}
+	audit.fail = true
+
+	tests := []struct {
+		name   string
+		mutate func() error
+	}{
+		{"Create", func() error {
+			_, err := svc.CreateEvent(model.Event{Date: testDay, Name: "retro", CreatorId: 1})
+			return err
+		}},
+		{"Update", func() error {
+			_, err := svc.UpdateEvent(model.Event{Id: event.Id, Date: testDay, Name: "renamed", CreatorId: 1})
+			return err
+		}},
+		{"Delete", func() error { return svc.DeleteEvent(model.Event{Id: event.Id, CreatorId: 1}) }},
+		{"Respond", func() error {
+			_, err := svc.RespondToInvitation(event.Id, 2, model.StatusAccepted)
+			return err
+		}},
+		{"Share", func() error { return svc.ShareCalendar(model.Share{OwnerId: 1, UserId: 4, Role: model.RoleWrite}) }},
+		{"Share new user", func() error { return svc.ShareCalendar(model.Share{OwnerId: 1, UserId: 5, Role: model.RoleRead}) }},
+		{"Unshare", func() error { return svc.UnshareCalendar(1, 4) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mutate(); !errors.Is(err, errAudit) {
+				t.Fatalf("error = %v, want %v", err, errAudit)
+			}
+			events, err := repo.GetByDay(testDay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].Name != "planning" || events[0].Attendees[0].Status != model.StatusPending {
+				t.Errorf("events after failed audit = %v, want only the unchanged event", events)
+			}
+			for userId, want := range map[uint]int{4: 1, 5: 0} {
+				shares, err := repo.GetShares(userId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(shares) != want || want == 1 && shares[0].Role != model.RoleRead {
+					t.Errorf("shares of user %d after failed audit = %v", userId, shares)
+				}
+			}
+		})
+	}
+}
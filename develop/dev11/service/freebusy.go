@@ -0,0 +1,111 @@
+package service
+
+import (
+	"dev11/model"
+	"slices"
+	"sort"
+	"time"
+)
+
+// FreeBusy возвращает занятость пользователей на периоде [from, to) и общие свободные окна
+// длительностью не меньше minDuration. События выбираются теми же недельными запросами,
+// что и для events_for_week
+func (s *EventService) FreeBusy(userIds []uint, from, to time.Time, minDuration time.Duration) (model.FreeBusy, error) {
+	if !from.Before(to) {
+		return model.FreeBusy{}, model.ErrInvalidPeriod
+	}
+
+	events, err := s.eventsBetween(from, to)
+	if err != nil {
+		return model.FreeBusy{}, err
+	}
+
+	period := model.Interval{Start: from, End: to}
+	result := model.FreeBusy{Users: make([]model.UserBusy, 0, len(userIds))}
+	all := make([]model.Interval, 0)
+	for _, userId := range userIds {
+		busy := make([]model.Interval, 0)
+		for _, event := range events {
+			if !event.IsBusy(userId) {
+				continue
+			}
+			if interval, ok := clip(event.Interval(), period); ok {
+				busy = append(busy, interval)
+			}
+		}
+		busy = mergeIntervals(busy)
+		all = append(all, busy...)
+		result.Users = append(result.Users, model.UserBusy{UserId: userId, Busy: busy})
+	}
+	result.Free = freeSlots(period, mergeIntervals(all), minDuration)
+	return result, nil
+}
+
+// eventsBetween собирает по неделям события, пересекающиеся с [from, to). Событие могло
+// начаться раньше from, поэтому поиск начинается на MaxDuration раньше
+func (s *EventService) eventsBetween(from, to time.Time) ([]model.Event, error) {
+	y, m, d := from.Add(-model.MaxDuration * time.Minute).Date()
+	period := model.Interval{Start: from, End: to}
+	events := make([]model.Event, 0)
+	seen := make(map[uint]struct{})
+	for week := time.Date(y, m, d, 0, 0, 0, 0, from.Location()); week.Before(to); week = week.AddDate(0, 0, 7) {
+		weekEvents, err := s.repo.GetByWeek(week)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range weekEvents {
+			if _, ok := seen[event.Id]; ok {
+				continue
+			}
+			seen[event.Id] = struct{}{}
+			if _, ok := clip(event.Interval(), period); ok {
+				events = append(events, event)
+			}
+		}
+	}
+	return events, nil
+}
+
+func clip(interval, period model.Interval) (model.Interval, bool) {
+	if interval.Start.Before(period.Start) {
+		interval.Start = period.Start
+	}
+	if interval.End.After(period.End) {
+		interval.End = period.End
+	}
+	return interval, interval.Start.Before(interval.End)
+}
+
+// mergeIntervals сортирует интервалы и объединяет пересекающиеся и смежные
+func mergeIntervals(intervals []model.Interval) []model.Interval {
+	intervals = slices.Clone(intervals)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+	merged := make([]model.Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// freeSlots возвращает промежутки периода между занятыми интервалами длиной не меньше minDuration
+func freeSlots(period model.Interval, busy []model.Interval, minDuration time.Duration) []model.Interval {
+	free := make([]model.Interval, 0)
+	start := period.Start
+	for _, interval := range busy {
+		if interval.Start.Sub(start) >= minDuration && interval.Start.After(start) {
+			free = append(free, model.Interval{Start: start, End: interval.Start})
+		}
+		start = interval.End
+	}
+	if period.End.Sub(start) >= minDuration && period.End.After(start) {
+		free = append(free, model.Interval{Start: start, End: period.End})
+	}
+	return free
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"dev11/model"
+	"dev11/repository"
+	"testing"
+	"time"
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2030, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func iv(start, end time.Time) model.Interval {
+	return model.Interval{Start: start, End: end}
+}
+
+func TestEventService_FreeBusy(t *testing.T) {
+	svc := NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore())
+	events := []model.Event{
+		{Date: at(4, 9, 0), Duration: 60, Name: "standup", CreatorId: 1},
+		{Date: at(4, 9, 30), Duration: 60, Name: "review", CreatorId: 2},
+		{Date: at(4, 13, 0), Duration: 30, Name: "lunch", CreatorId: 3, Attendees: []model.Attendee{{UserId: 1}}},
+		{Date: at(4, 15, 0), Duration: 60, Name: "declined", CreatorId: 3, Attendees: []model.Attendee{{UserId: 2}}},
+		{Date: at(12, 0, 0), Name: "next week", CreatorId: 1},
+	}
+	for _, e := range events {
+		if _, err := svc.CreateEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.RespondToInvitation(4, 2, model.StatusDeclined); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.FreeBusy([]uint{1, 2}, at(4, 8, 0), at(4, 18, 0), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBusy := map[uint][]model.Interval{
+		1: {iv(at(4, 9, 0), at(4, 10, 0)), iv(at(4, 13, 0), at(4, 13, 30))},
+		2: {iv(at(4, 9, 30), at(4, 10, 30))},
+	}
+	for _, user := range got.Users {
+		assertIntervals(t, "busy", user.Busy, wantBusy[user.UserId])
+	}
+	wantFree := []model.Interval{
+		iv(at(4, 8, 0), at(4, 9, 0)),
+		iv(at(4, 10, 30), at(4, 13, 0)),
+		iv(at(4, 13, 30), at(4, 18, 0)),
+	}
+	assertIntervals(t, "free", got.Free, wantFree)
+
+	if _, err = svc.FreeBusy([]uint{1}, at(4, 18, 0), at(4, 8, 0), 0); err != model.ErrInvalidPeriod {
+		t.Errorf("FreeBusy() error = %v, want %v", err, model.ErrInvalidPeriod)
+	}
+}
+
+func Test_mergeIntervals(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []model.Interval
+		want []model.Interval
+	}{
+		{"Empty", nil, []model.Interval{}},
+		{"Overlapping", []model.Interval{iv(at(1, 10, 0), at(1, 12, 0)), iv(at(1, 9, 0), at(1, 11, 0))}, []model.Interval{iv(at(1, 9, 0), at(1, 12, 0))}},
+		{"Adjacent", []model.Interval{iv(at(1, 9, 0), at(1, 10, 0)), iv(at(1, 10, 0), at(1, 11, 0))}, []model.Interval{iv(at(1, 9, 0), at(1, 11, 0))}},
+		{"Nested", []model.Interval{iv(at(1, 9, 0), at(1, 17, 0)), iv(at(1, 10, 0), at(1, 11, 0))}, []model.Interval{iv(at(1, 9, 0), at(1, 17, 0))}},
+		{"Disjoint", []model.Interval{iv(at(1, 12, 0), at(1, 13, 0)), iv(at(1, 9, 0), at(1, 10, 0))}, []model.Interval{iv(at(1, 9, 0), at(1, 10, 0)), iv(at(1, 12, 0), at(1, 13, 0))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIntervals(t, "merged", mergeIntervals(tt.in), tt.want)
+		})
+	}
+}
+
+func assertIntervals(t *testing.T, name string, got, want []model.Interval) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", name, got, want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+			return
+		}
+	}
+}
+
+func TestEventService_FreeBusyEventStartedBefore(t *testing.T) {
+	svc := NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore())
+	events := []model.Event{
+		{Date: at(1, 23, 0), Duration: 180, Name: "night shift", CreatorId: 1},
+		{Date: at(1, 20, 0), Duration: 60, Name: "ended before", CreatorId: 1},
+		{Date: at(1, 12, 0), Duration: model.MaxDuration, Name: "week long", CreatorId: 2},
+	}
+	for _, e := range events {
+		if _, err := svc.CreateEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := svc.FreeBusy([]uint{1, 2}, at(2, 0, 0), at(2, 6, 0), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBusy := map[uint][]model.Interval{
+		1: {iv(at(2, 0, 0), at(2, 2, 0))},
+		2: {iv(at(2, 0, 0), at(2, 6, 0))},
+	}
+	for _, user := range got.Users {
+		assertIntervals(t, "busy", user.Busy, wantBusy[user.UserId])
+	}
+	assertIntervals(t, "free", got.Free, []model.Interval{})
+
+	got, err = svc.FreeBusy([]uint{1}, at(2, 0, 0), at(2, 6, 0), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIntervals(t, "free", got.Free, []model.Interval{iv(at(2, 2, 0), at(2, 6, 0))})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"dev11/repository"
 	server2 "dev11/server"
 	service2 "dev11/service"
 	"encoding/json"
@@ -42,12 +43,19 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err = config.CORS.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println(config)
-	service := &service2.FakeEventService{}
+	service := service2.NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore())
 	server := server2.NewServer(service)
+	server.CORS = config.CORS
+	server.CSRF = config.CSRF
 	server.Start(config.Port)
 }
 
 type config struct {
-	Port uint16 `json:"port"`
+	Port uint16             `json:"port"`
+	CORS server2.CORSConfig `json:"cors"`
+	CSRF server2.CSRFConfig `json:"csrf"`
 }
@@ -1 +1,676 @@
 package main
+
+import (
+	"bytes"
+	"dev11/model"
+	"dev11/repository"
+	"dev11/server"
+	"dev11/service"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// harness поднимает настоящий Server на httptest поверх переданного хранилища
+type harness struct {
+	t   *testing.T
+	srv *httptest.Server
+}
+
+func newHarness(t *testing.T, repo service.Repository) *harness {
+	t.Helper()
+	return newServerHarness(t, server.NewServer(service.NewEventService(repo, repository.NewMemoryAuditStore())))
+}
+
+func newServerHarness(t *testing.T, s *server.Server) *harness {
+	t.Helper()
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return &harness{t: t, srv: srv}
+}
+
+func (h *harness) do(method, path, values string) (int, []byte) {
+	h.t.Helper()
+	resp, body := h.doWithHeaders(method, path, values, nil)
+	return resp.StatusCode, body
+}
+
+func (h *harness) doWithHeaders(method, path, values string, headers map[string]string) (*http.Response, []byte) {
+	h.t.Helper()
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, h.srv.URL+path+"?"+values, nil)
+	} else {
+		req, err = http.NewRequest(method, h.srv.URL+path, strings.NewReader(values))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := h.srv.Client().Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return resp, body
+}
+
+// seed создает событие 1 пользователя 1 с участниками 2 и 3
+// и открывает календарь пользователя 1 пользователю 4 на чтение и 5 на запись
+func (h *harness) seed() {
+	h.t.Helper()
+	requests := []struct{ path, values string }{
+		{"/create_event", "user_id=1&name=Planning&date=2099-03-02T10:00&duration=60&attendees=2,3&description=Quarter"},
+		{"/share_calendar", "owner_id=1&user_id=4&role=read"},
+		{"/share_calendar", "owner_id=1&user_id=5&role=write"},
+	}
+	for _, r := range requests {
+		if status, body := h.do(http.MethodPost, r.path, r.values); status >= 300 {
+			h.t.Fatalf("seed %s: %d %s", r.path, status, body)
+		}
+	}
+}
+
+type endpointCase struct {
+	name       string
+	method     string
+	path       string
+	values     string
+	wantStatus int
+	golden     string
+}
+
+func runCases(t *testing.T, h *harness, cases []endpointCase) {
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := h.do(tt.method, tt.path, tt.values)
+			if status != tt.wantStatus {
+				t.Fatalf("%s %s: status = %d, want %d, body %s", tt.method, tt.path, status, tt.wantStatus, body)
+			}
+			assertEnvelope(t, status, body)
+			if tt.golden != "" {
+				assertGolden(t, tt.golden, body)
+			}
+		})
+	}
+}
+
+func Test_endpoints(t *testing.T) {
+	h := newHarness(t, repository.NewMemoryRepository())
+	h.seed()
+
+	get, post := http.MethodGet, http.MethodPost
+	runCases(t, h, []endpointCase{
+		{"create_event success", post, "/create_event", "user_id=2&name=Lunch&date=2099-03-02T13:00&duration=30&attendees=1", http.StatusCreated, "create_event"},
+		{"create_event all-day", post, "/create_event", "user_id=3&name=Holiday&date=2099-03-03", http.StatusCreated, "create_event_all_day"},
+		{"create_event time without duration", post, "/create_event", "user_id=6&name=Call&date=2099-03-04T15:00", http.StatusBadRequest, ""},
+		{"create_event time with zero duration", post, "/create_event", "user_id=6&name=Call&date=2099-03-04T15:00&duration=0", http.StatusBadRequest, ""},
+		{"create_event all-day with duration", post, "/create_event", "user_id=6&name=Call&date=2099-03-04&duration=30", http.StatusBadRequest, ""},
+		{"freebusy without the timed event", get, "/freebusy", "user_ids=6&from=2099-03-04&to=2099-03-04", http.StatusOK, "freebusy_no_events"},
+		{"create_event missing name", post, "/create_event", "user_id=1&date=2099-03-02", http.StatusBadRequest, ""},
+		{"create_event blank name", post, "/create_event", "user_id=1&name=%20%20&date=2099-03-02", http.StatusBadRequest, ""},
+		{"create_event past date", post, "/create_event", "user_id=1&name=Old&date=2000-01-01", http.StatusBadRequest, ""},
+		{"create_event bad user_id", post, "/create_event", "user_id=abc&name=X&date=2099-03-02", http.StatusBadRequest, ""},
+		{"create_event bad attendees", post, "/create_event", "user_id=1&name=X&date=2099-03-02&attendees=2,x", http.StatusBadRequest, ""},
+		{"create_event query is not a body", post, "/create_event?user_id=1&name=X&date=2099-03-02", "", http.StatusBadRequest, ""},
+		{"create_event GET", get, "/create_event", "", http.StatusMethodNotAllowed, ""},
+
+		{"respond_invitation accept", post, "/respond_invitation", "event_id=1&user_id=2&status=accepted", http.StatusOK, "respond_invitation"},
+		{"respond_invitation decline", post, "/respond_invitation", "event_id=1&user_id=3&status=declined", http.StatusOK, ""},
+		{"respond_invitation bad status", post, "/respond_invitation", "event_id=1&user_id=2&status=maybe", http.StatusBadRequest, ""},
+		{"respond_invitation not invited", post, "/respond_invitation", "event_id=1&user_id=9&status=accepted", http.StatusServiceUnavailable, ""},
+		{"respond_invitation unknown event", post, "/respond_invitation", "event_id=99&user_id=2&status=accepted", http.StatusServiceUnavailable, ""},
+		{"respond_invitation GET", get, "/respond_invitation", "event_id=1&user_id=2&status=accepted", http.StatusMethodNotAllowed, ""},
+
+		{"update_event by owner", post, "/update_event", "id=1&user_id=1&name=Planning&date=2099-03-02T11:00&duration=90&attendees=2,6", http.StatusOK, "update_event"},
+		{"update_event by writer", post, "/update_event", "id=1&user_id=5&name=Planning&date=2099-03-02T11:00&duration=90", http.StatusOK, ""},
+		{"update_event by reader", post, "/update_event", "id=1&user_id=4&name=Hijack&date=2099-03-02", http.StatusServiceUnavailable, ""},
+		{"update_event unknown event", post, "/update_event", "id=99&user_id=1&name=X&date=2099-03-02", http.StatusServiceUnavailable, ""},
+		{"update_event missing id", post, "/update_event", "user_id=1&name=X&date=2099-03-02", http.StatusBadRequest, ""},
+		{"update_event GET", get, "/update_event", "", http.StatusMethodNotAllowed, ""},
+
+		{"events_for_day owner", get, "/events_for_day", "user_id=1&date=2099-03-02", http.StatusOK, "events_for_day_owner"},
+		{"events_for_day attendee", get, "/events_for_day", "user_id=6&date=2099-03-02", http.StatusOK, "events_for_day_attendee"},
+		{"events_for_day shared reader", get, "/events_for_day", "user_id=4&date=2099-03-02", http.StatusOK, "events_for_day_reader"},
+		{"events_for_day stranger", get, "/events_for_day", "user_id=8&date=2099-03-02", http.StatusOK, "events_empty"},
+		{"events_for_day missing date", get, "/events_for_day", "user_id=1", http.StatusBadRequest, ""},
+		{"events_for_day bad date", get, "/events_for_day", "user_id=1&date=02.03.2099", http.StatusBadRequest, ""},
+		{"events_for_day negative user", get, "/events_for_day", "user_id=-1&date=2099-03-02", http.StatusBadRequest, ""},
+		{"events_for_day POST", post, "/events_for_day", "user_id=1&date=2099-03-02", http.StatusMethodNotAllowed, ""},
+		{"events_for_week", get, "/events_for_week", "user_id=3&date=2099-02-28", http.StatusOK, "events_for_week"},
+		{"events_for_week missing user", get, "/events_for_week", "date=2099-02-28", http.StatusBadRequest, ""},
+		{"events_for_week POST", post, "/events_for_week", "", http.StatusMethodNotAllowed, ""},
+		{"events_for_month", get, "/events_for_month", "user_id=2&date=2099-03-20", http.StatusOK, "events_for_month"},
+		{"events_for_month bad user", get, "/events_for_month", "user_id=two&date=2099-03-20", http.StatusBadRequest, ""},
+		{"events_for_month POST", post, "/events_for_month", "", http.StatusMethodNotAllowed, ""},
+
+		{"freebusy", get, "/freebusy", "user_ids=1,2&from=2099-03-02T08:00&to=2099-03-02T18:00&min_duration=60", http.StatusOK, "freebusy"},
+		{"freebusy whole day", get, "/freebusy", "user_ids=3&from=2099-03-02&to=2099-03-03", http.StatusOK, "freebusy_whole_day"},
+		{"freebusy reversed period", get, "/freebusy", "user_ids=1&from=2099-03-03&to=2099-03-02", http.StatusBadRequest, ""},
+		{"freebusy missing users", get, "/freebusy", "from=2099-03-02&to=2099-03-03", http.StatusBadRequest, ""},
+		{"freebusy empty users", get, "/freebusy", "user_ids=&from=2099-03-02&to=2099-03-03", http.StatusBadRequest, ""},
+		{"freebusy POST", post, "/freebusy", "", http.StatusMethodNotAllowed, ""},
+
+		{"share_calendar", post, "/share_calendar", "owner_id=2&user_id=1&role=read", http.StatusOK, "share_calendar"},
+		{"share_calendar with owner", post, "/share_calendar", "owner_id=2&user_id=2&role=write", http.StatusServiceUnavailable, ""},
+		{"share_calendar bad role", post, "/share_calendar", "owner_id=2&user_id=1&role=admin", http.StatusBadRequest, ""},
+		{"share_calendar GET", get, "/share_calendar", "", http.StatusMethodNotAllowed, ""},
+		{"unshare_calendar", post, "/unshare_calendar", "owner_id=1&user_id=4", http.StatusOK, "unshare_calendar"},
+		{"events_for_day after unshare", get, "/events_for_day", "user_id=4&date=2099-03-02", http.StatusOK, "events_empty"},
+		{"unshare_calendar missing owner", post, "/unshare_calendar", "user_id=4", http.StatusBadRequest, ""},
+		{"unshare_calendar GET", get, "/unshare_calendar", "", http.StatusMethodNotAllowed, ""},
+
+		{"delete_event by reader", post, "/delete_event", "id=1&user_id=6", http.StatusServiceUnavailable, ""},
+		{"delete_event bad id", post, "/delete_event", "id=one&user_id=1", http.StatusBadRequest, ""},
+		{"delete_event GET", get, "/delete_event", "id=1&user_id=1", http.StatusMethodNotAllowed, ""},
+		{"delete_event by owner", post, "/delete_event", "id=1&user_id=1", http.StatusOK, "delete_event"},
+		{"delete_event twice", post, "/delete_event", "id=1&user_id=1", http.StatusServiceUnavailable, ""},
+
+		{"openapi.json", get, "/openapi.json", "", http.StatusOK, ""},
+		{"openapi.json POST", post, "/openapi.json", "", http.StatusMethodNotAllowed, ""},
+	})
+}
+
+func Test_endpointsStorageFailure(t *testing.T) {
+	h := newHarness(t, failingRepository{})
+
+	get, post := http.MethodGet, http.MethodPost
+	runCases(t, h, []endpointCase{
+		{"create_event", post, "/create_event", "user_id=1&name=X&date=2099-03-02", http.StatusInternalServerError, "internal_error"},
+		{"update_event", post, "/update_event", "id=1&user_id=1&name=X&date=2099-03-02", http.StatusInternalServerError, "internal_error"},
+		{"delete_event", post, "/delete_event", "id=1&user_id=1", http.StatusInternalServerError, "internal_error"},
+		{"events_for_day", get, "/events_for_day", "user_id=1&date=2099-03-02", http.StatusInternalServerError, "internal_error"},
+		{"events_for_week", get, "/events_for_week", "user_id=1&date=2099-03-02", http.StatusInternalServerError, "internal_error"},
+		{"events_for_month", get, "/events_for_month", "user_id=1&date=2099-03-02", http.StatusInternalServerError, "internal_error"},
+		{"respond_invitation", post, "/respond_invitation", "event_id=1&user_id=2&status=accepted", http.StatusInternalServerError, "internal_error"},
+		{"share_calendar", post, "/share_calendar", "owner_id=1&user_id=2&role=read", http.StatusInternalServerError, "internal_error"},
+		{"unshare_calendar", post, "/unshare_calendar", "owner_id=1&user_id=2", http.StatusInternalServerError, "internal_error"},
+		{"freebusy", get, "/freebusy", "user_ids=1&from=2099-03-02&to=2099-03-02", http.StatusInternalServerError, "internal_error"},
+	})
+}
+
+func Test_openAPI(t *testing.T) {
+	h := newHarness(t, repository.NewMemoryRepository())
+	status, body := h.do(http.MethodGet, "/openapi.json", "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	assertGolden(t, "openapi", body)
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/create_event", "/update_event", "/delete_event", "/events_for_day",
+		"/events_for_week", "/events_for_month", "/respond_invitation", "/share_calendar", "/unshare_calendar", "/freebusy", "/audit", "/calendar_audit"} {
+		ops, ok := doc.Paths[path]
+		if !ok || len(ops) != 1 {
+			t.Errorf("openapi.json does not describe %s", path)
+			continue
+		}
+		for method, op := range ops {
+			for _, code := range []string{"400", "405", "500", "503"} {
+				if _, ok := op.Responses[code]; !ok {
+					t.Errorf("%s %s: response %s is not documented", method, path, code)
+				}
+			}
+		}
+	}
+}
+
+func Test_concurrentMutations(t *testing.T) {
+	h := newHarness(t, repository.NewMemoryRepository())
+	const workers = 32
+
+	invited := make([]string, 0, workers)
+	for i := 0; i < workers; i++ {
+		invited = append(invited, fmt.Sprint(100+i))
+	}
+	if status, body := h.do(http.MethodPost, "/create_event",
+		"user_id=1&name=All-hands&date=2099-04-01&attendees="+strings.Join(invited, ",")); status != http.StatusCreated {
+		t.Fatalf("create: %d %s", status, body)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			values := fmt.Sprintf("user_id=2&name=Event %d&date=2099-04-01T%02d:00&duration=30", i, i%24)
+			if status, body := h.do(http.MethodPost, "/create_event", values); status != http.StatusCreated {
+				errs <- fmt.Errorf("create %d: %d %s", i, status, body)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			values := fmt.Sprintf("event_id=1&user_id=%d&status=accepted", 100+i)
+			if status, body := h.do(http.MethodPost, "/respond_invitation", values); status != http.StatusOK {
+				errs <- fmt.Errorf("respond %d: %d %s", i, status, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var created successResponse[[]model.Event]
+	_, body := h.do(http.MethodGet, "/events_for_day", "user_id=2&date=2099-04-01")
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[uint]struct{})
+	for _, e := range created.Result {
+		ids[e.Id] = struct{}{}
+	}
+	if len(created.Result) != workers || len(ids) != workers {
+		t.Errorf("got %d events with %d unique ids, want %d", len(created.Result), len(ids), workers)
+	}
+
+	var allHands successResponse[[]model.Event]
+	_, body = h.do(http.MethodGet, "/events_for_day", "user_id=1&date=2099-04-01")
+	if err := json.Unmarshal(body, &allHands); err != nil {
+		t.Fatal(err)
+	}
+	if len(allHands.Result) != 1 {
+		t.Fatalf("got %d events for the owner, want 1", len(allHands.Result))
+	}
+	for _, a := range allHands.Result[0].Attendees {
+		if a.Status != model.StatusAccepted {
+			t.Errorf("attendee %d lost the answer: %s", a.UserId, a.Status)
+		}
+	}
+}
+
+func Test_cors(t *testing.T) {
+	s := server.NewServer(service.NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore()))
+	s.CORS = server.CORSConfig{
+		AllowedOrigins: []string{"http://front.example"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-Id"},
+		MaxAge:         600,
+	}
+	h := newServerHarness(t, s)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		headers     map[string]string
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+	}{
+		{"Preflight from allowed origin", http.MethodOptions, "/create_event", map[string]string{
+			"Origin": "http://front.example", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type, x-request-id",
+		}, http.StatusNoContent, "http://front.example", "GET, POST"},
+		{"Preflight from foreign origin", http.MethodOptions, "/create_event", map[string]string{
+			"Origin": "http://evil.example", "Access-Control-Request-Method": "POST",
+		}, http.StatusForbidden, "", ""},
+		{"Preflight for disallowed method", http.MethodOptions, "/create_event", map[string]string{
+			"Origin": "http://front.example", "Access-Control-Request-Method": "DELETE",
+		}, http.StatusForbidden, "", ""},
+		{"Preflight for disallowed header", http.MethodOptions, "/create_event", map[string]string{
+			"Origin": "http://front.example", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "authorization",
+		}, http.StatusForbidden, "", ""},
+		{"Simple GET from allowed origin", http.MethodGet, "/events_for_day", map[string]string{
+			"Origin": "http://front.example",
+		}, http.StatusBadRequest, "http://front.example", ""},
+		{"Simple GET from foreign origin", http.MethodGet, "/events_for_day", map[string]string{
+			"Origin": "http://evil.example",
+		}, http.StatusBadRequest, "", ""},
+		{"OPTIONS without preflight headers", http.MethodOptions, "/create_event", nil, http.StatusMethodNotAllowed, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := h.doWithHeaders(tt.method, tt.path, "", tt.headers)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+		})
+	}
+
+	wildcardTests := []struct {
+		name            string
+		credentials     bool
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{"Wildcard without credentials", false, "*", ""},
+		{"Wildcard with credentials", true, "", ""},
+	}
+	for _, tt := range wildcardTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server.NewServer(service.NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore()))
+			s.CORS = server.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: tt.credentials}
+			if err := s.CORS.Validate(); (err != nil) != tt.credentials {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.credentials)
+			}
+			resp, _ := newServerHarness(t, s).doWithHeaders(http.MethodGet, "/events_for_day", "", map[string]string{"Origin": "http://evil.example"})
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+		})
+	}
+}
+
+func Test_csrf(t *testing.T) {
+	s := server.NewServer(service.NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore()))
+	s.CORS = server.CORSConfig{AllowedOrigins: []string{"http://front.example"}}
+	s.CSRF = server.CSRFConfig{Enabled: true}
+	h := newServerHarness(t, s)
+
+	const form = "user_id=1&name=Demo&date=2099-05-01"
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"Non-browser client", nil, http.StatusCreated},
+		{"Allowed origin", map[string]string{"Origin": "http://front.example"}, http.StatusCreated},
+		{"Same origin", map[string]string{"Origin": h.srv.URL}, http.StatusCreated},
+		{"Foreign origin", map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{"Foreign referer", map[string]string{"Referer": "http://evil.example/page"}, http.StatusForbidden},
+		{"Allowed referer", map[string]string{"Referer": "http://front.example/calendar"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := h.doWithHeaders(http.MethodPost, "/create_event", form, tt.headers)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			assertEnvelope(t, resp.StatusCode, body)
+		})
+	}
+
+	// "*" в CORS разрешает чтение, но не делает чужие источники доверенными для POST
+	s = server.NewServer(service.NewEventService(repository.NewMemoryRepository(), repository.NewMemoryAuditStore()))
+	s.CORS = server.CORSConfig{AllowedOrigins: []string{"*"}}
+	s.CSRF = server.CSRFConfig{Enabled: true}
+	h = newServerHarness(t, s)
+	wildcardTests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"Wildcard: foreign origin", map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{"Wildcard: foreign referer", map[string]string{"Referer": "http://evil.example/page"}, http.StatusForbidden},
+		{"Wildcard: same origin", map[string]string{"Origin": h.srv.URL}, http.StatusCreated},
+	}
+	for _, tt := range wildcardTests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := h.doWithHeaders(http.MethodPost, "/create_event", form, tt.headers)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", resp.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func Test_rpc(t *testing.T) {
+	h := newHarness(t, repository.NewMemoryRepository())
+	jsonHeaders := map[string]string{"Content-Type": "application/json"}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		golden     string
+	}{
+		{"Single call", `{"jsonrpc": "2.0", "method": "create_event", "id": 1,
+			"params": {"user_id": 1, "name": "Sync", "date": "2099-06-01T10:00", "duration": 30, "attendees": [2, 3]}}`,
+			http.StatusOK, "rpc_single"},
+		{"Notification only", `{"jsonrpc": "2.0", "method": "respond_invitation",
+			"params": {"event_id": 1, "user_id": 2, "status": "accepted"}}`, http.StatusNoContent, ""},
+		{"Batch", `[
+			{"jsonrpc": "2.0", "method": "events_for_day", "params": {"user_id": 2, "date": "2099-06-01"}, "id": "day"},
+			{"jsonrpc": "2.0", "method": "create_event", "params": {"user_id": 2, "name": "Silent", "date": "2099-06-01"}},
+			{"jsonrpc": "2.0", "method": "events_for_day", "params": {"user_id": "two", "date": "2099-06-01"}, "id": 2},
+			{"jsonrpc": "2.0", "method": "drop_database", "id": 3},
+			{"jsonrpc": "2.0", "method": "update_event", "params": {"id": 99, "user_id": 1, "name": "X", "date": "2099-06-01"}, "id": 4},
+			{"jsonrpc": "2.0", "method": "freebusy", "params": {"user_ids": [1, 2], "from": "2099-06-01", "to": "2099-06-01", "min_duration": 120}, "id": 5},
+			{"jsonrpc": "2.0", "method": "events_for_week", "params": [2, "2099-06-01"], "id": 6},
+			{"foo": "bar"},
+			{"jsonrpc": "2.0", "method": "delete_event", "params": {"id": 1, "user_id": 1}, "id": null}
+		]`, http.StatusOK, "rpc_batch"},
+		{"Batch of notifications", `[{"jsonrpc": "2.0", "method": "unshare_calendar", "params": {"owner_id": 1, "user_id": 2}}]`,
+			http.StatusNoContent, ""},
+		{"Empty batch", `[]`, http.StatusOK, "rpc_empty_batch"},
+		{"Parse error", `{"jsonrpc": "2.0", "method"`, http.StatusOK, "rpc_parse_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := h.doWithHeaders(http.MethodPost, "/rpc", tt.body, jsonHeaders)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.golden != "" {
+				assertGolden(t, tt.golden, body)
+			} else if len(body) != 0 {
+				t.Errorf("notifications must not be answered, got %s", body)
+			}
+		})
+	}
+
+	if status, _ := h.do(http.MethodGet, "/rpc", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET /rpc status = %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+
+	var events successResponse[[]model.Event]
+	_, body := h.do(http.MethodGet, "/events_for_day", "user_id=2&date=2099-06-01")
+	if err := json.Unmarshal(body, &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Result) != 1 || events.Result[0].Name != "Silent" {
+		t.Errorf("notifications were not executed, events: %s", body)
+	}
+}
+
+func Test_audit(t *testing.T) {
+	h := newHarness(t, repository.NewMemoryRepository())
+	h.seed()
+
+	mutations := []struct{ path, values string }{
+		{"/update_event", "id=1&user_id=5&name=Planning&date=2099-03-02T10:00&duration=45&attendees=2,3&description=Quarter"},
+		{"/respond_invitation", "event_id=1&user_id=3&status=declined"},
+		{"/update_event", "id=1&user_id=4&name=Hijack&date=2099-03-02"},
+		{"/delete_event", "id=1&user_id=1"},
+		{"/share_calendar", "owner_id=1&user_id=4&role=write"},
+		{"/unshare_calendar", "owner_id=1&user_id=4"},
+		{"/unshare_calendar", "owner_id=1&user_id=6"},
+	}
+	for _, m := range mutations {
+		h.do(http.MethodPost, m.path, m.values)
+	}
+
+	status, body := h.do(http.MethodGet, "/audit", "event_id=1")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, body %s", status, body)
+	}
+	var records successResponse[[]model.AuditRecord]
+	if err := json.Unmarshal(body, &records); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		action  model.AuditAction
+		userId  uint
+		changes []string
+	}{
+		{model.ActionCreate, 1, []string{"date", "name", "description", "user_id", "duration", "attendees"}},
+		{model.ActionUpdate, 5, []string{"duration"}},
+		{model.ActionRespond, 3, []string{"attendees"}},
+		{model.ActionDelete, 1, []string{"date", "name", "description", "user_id", "duration", "attendees"}},
+	}
+	if len(records.Result) != len(want) {
+		t.Fatalf("got %d audit records, want %d: %s", len(records.Result), len(want), body)
+	}
+	for i, w := range want {
+		got := records.Result[i]
+		fields := make([]string, 0, len(got.Changes))
+		for _, c := range got.Changes {
+			fields = append(fields, c.Field)
+		}
+		if got.Action != w.action || got.UserId != w.userId || strings.Join(fields, ",") != strings.Join(w.changes, ",") {
+			t.Errorf("record %d = %s by %d changing %v, want %s by %d changing %v",
+				i, got.Action, got.UserId, fields, w.action, w.userId, w.changes)
+		}
+		if got.EventId != 1 || got.Time.IsZero() {
+			t.Errorf("record %d: event_id = %d, time = %v", i, got.EventId, got.Time)
+		}
+		if (got.Before == nil) != (w.action == model.ActionCreate) || (got.After == nil) != (w.action == model.ActionDelete) {
+			t.Errorf("record %d: before = %v, after = %v", i, got.Before, got.After)
+		}
+	}
+
+	status, body = h.do(http.MethodGet, "/calendar_audit", "owner_id=1")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, body %s", status, body)
+	}
+	var shares successResponse[[]model.AuditRecord]
+	if err := json.Unmarshal(body, &shares); err != nil {
+		t.Fatal(err)
+	}
+	wantShares := []struct {
+		action        model.AuditAction
+		userId        uint
+		before, after any
+	}{
+		{model.ActionShare, 4, nil, "read"},
+		{model.ActionShare, 5, nil, "write"},
+		{model.ActionShare, 4, "read", "write"},
+		{model.ActionUnshare, 4, "write", nil},
+	}
+	if len(shares.Result) != len(wantShares) {
+		t.Fatalf("got %d calendar audit records, want %d: %s", len(shares.Result), len(wantShares), body)
+	}
+	for i, w := range wantShares {
+		got := shares.Result[i]
+		if got.Action != w.action || got.UserId != 1 || got.EventId != 0 || got.Share == nil || got.Share.UserId != w.userId {
+			t.Errorf("record %d = %s by %d sharing %v, want %s to user %d", i, got.Action, got.UserId, got.Share, w.action, w.userId)
+			continue
+		}
+		if len(got.Changes) != 1 || got.Changes[0].Before != w.before || got.Changes[0].After != w.after {
+			t.Errorf("record %d: changes = %v, want role %v -> %v", i, got.Changes, w.before, w.after)
+		}
+	}
+
+	runCases(t, h, []endpointCase{
+		{"audit of unknown event", http.MethodGet, "/audit", "event_id=42", http.StatusOK, "events_empty"},
+		{"audit missing event_id", http.MethodGet, "/audit", "", http.StatusBadRequest, ""},
+		{"audit POST", http.MethodPost, "/audit", "event_id=1", http.StatusMethodNotAllowed, ""},
+		{"calendar audit missing owner_id", http.MethodGet, "/calendar_audit", "", http.StatusBadRequest, ""},
+	})
+}
+
+type successResponse[T any] struct {
+	Result T `json:"result"`
+}
+
+// assertEnvelope проверяет контракт ответа: {"result": ...} при успехе и {"error": "..."} при ошибке
+func assertEnvelope(t *testing.T, status int, body []byte) {
+	t.Helper()
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("response is not a JSON object: %s", body)
+	}
+	if status >= 400 {
+		var msg string
+		if err := json.Unmarshal(envelope["error"], &msg); err != nil || msg == "" || len(envelope) != 1 {
+			t.Errorf("error response must be {\"error\": \"...\"}, got %s", body)
+		}
+		return
+	}
+	if _, ok := envelope["result"]; !ok && envelope["openapi"] == nil {
+		t.Errorf("success response must contain result, got %s", body)
+	}
+}
+
+// assertGolden сравнивает ответ с testdata/<name>.golden, с флагом -update перезаписывает файл
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, body, "", "  "); err != nil {
+		t.Fatalf("response is not JSON: %s", body)
+	}
+	pretty.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, pretty.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create golden files", err)
+	}
+	if !bytes.Equal(pretty.Bytes(), want) {
+		t.Errorf("response differs from %s:\ngot:\n%s\nwant:\n%s", path, pretty.Bytes(), want)
+	}
+}
+
+var errStorage = errors.New("storage is unavailable")
+
+// failingRepository имитирует недоступное хранилище
+type failingRepository struct{}
+
+func (failingRepository) Add(model.Event) (model.Event, error)    { return model.Event{}, errStorage }
+func (failingRepository) Update(model.Event) (model.Event, error) { return model.Event{}, errStorage }
+func (failingRepository) Delete(uint) error                       { return errStorage }
+func (failingRepository) Restore(model.Event) error               { return errStorage }
+func (failingRepository) GetById(uint) (model.Event, error)       { return model.Event{}, errStorage }
+func (failingRepository) GetByDay(time.Time) ([]model.Event, error) {
+	return nil, errStorage
+}
+func (failingRepository) GetByWeek(time.Time) ([]model.Event, error) {
+	return nil, errStorage
+}
+func (failingRepository) GetByMonth(time.Month, int) ([]model.Event, error) {
+	return nil, errStorage
+}
+func (failingRepository) SetShare(model.Share) error            { return errStorage }
+func (failingRepository) DeleteShare(uint, uint) error          { return errStorage }
+func (failingRepository) GetShares(uint) ([]model.Share, error) { return nil, errStorage }